@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// 批量嗅探的条目数上限和整批截止时间
+const (
+	maxBatchItems        = 500
+	defaultBatchDeadline = 5 * time.Minute
+	maxBatchDeadline     = 30 * time.Minute
+)
+
+// batchRequest POST /sniffer/batch 的请求体
+type batchRequest struct {
+	URLs     []string          `json:"urls"`     // 只有地址的条目，使用共享参数
+	Items    []json.RawMessage `json:"items"`    // 单独指定参数的条目，格式与 POST /sniffer 相同，字段覆盖共享参数
+	Options  json.RawMessage   `json:"options"`  // 所有条目共享的参数，格式与 POST /sniffer 相同但不含 url，可以包含 cache
+	Deadline int               `json:"deadline"` // 整批截止时间，单位毫秒，默认 300000，最大 1800000
+}
+
+// batchItem 校验后的批量条目
+type batchItem struct {
+	index   int
+	req     *snifferRequest
+	present func(key string) bool
+}
+
+// batchItemResult 单个条目的嗅探结果
+type batchItemResult struct {
+	Index int                    `json:"index"` // 条目序号，先 urls 后 items
+	URL   string                 `json:"url"`
+	Code  int                    `json:"code"`
+	Msg   string                 `json:"msg"`
+	Data  map[string]interface{} `json:"data,omitempty"` // 与 /sniffer 响应的 data 相同
+}
+
+// batchSummary 批量嗅探汇总
+type batchSummary struct {
+	Total            int               `json:"total"`
+	Completed        int               `json:"completed"` // 已执行的条目数，包括截止时被取消的条目
+	Found            int               `json:"found"`     // 嗅探到地址的条目数
+	DeadlineExceeded bool              `json:"deadline_exceeded"`
+	Cost             string            `json:"cost"`
+	Items            []batchItemResult `json:"items,omitempty"`
+	Done             bool              `json:"done,omitempty"` // NDJSON 输出中标记最后一行
+}
+
+// parseBatchRequest 解析并校验批量请求，每个条目的参数由共享参数和条目参数合并而成
+func parseBatchRequest(body []byte) ([]batchItem, time.Duration, []fieldError) {
+	var req batchRequest
+	if err := decodeStrict(body, &req); err != nil {
+		return nil, 0, []fieldError{decodeError(err)}
+	}
+
+	var errs []fieldError
+	total := len(req.URLs) + len(req.Items)
+	switch {
+	case total == 0:
+		errs = append(errs, fieldError{Field: "items", Message: "urls 和 items 不能都为空"})
+	case total > maxBatchItems:
+		errs = append(errs, fieldError{Field: "items", Message: fmt.Sprintf("条目数不能超过 %d 个", maxBatchItems)})
+	}
+	if req.Deadline < 0 || time.Duration(req.Deadline)*time.Millisecond > maxBatchDeadline {
+		errs = append(errs, fieldError{Field: "deadline", Message: fmt.Sprintf("必须在 0 到 %d 之间", maxBatchDeadline.Milliseconds())})
+	}
+
+	// 共享参数先单独解析，类型错误和未知字段只报告一次
+	shared := map[string]json.RawMessage{}
+	if len(req.Options) > 0 && string(req.Options) != "null" {
+		var opts struct {
+			Cache string `json:"cache"`
+			sniffer.SnifferOptions
+		}
+		if err := decodeStrict(req.Options, &opts); err != nil {
+			fe := decodeError(err)
+			fe.Field = strings.TrimSuffix("options."+fe.Field, ".")
+			return nil, 0, append(errs, fe)
+		}
+		_ = json.Unmarshal(req.Options, &shared)
+	}
+	if len(errs) > 0 {
+		return nil, 0, errs
+	}
+
+	raws := make([]json.RawMessage, 0, total)
+	for _, u := range req.URLs {
+		raw, _ := json.Marshal(map[string]string{"url": u})
+		raws = append(raws, raw)
+	}
+	raws = append(raws, req.Items...)
+
+	// 错误字段按条目来源命名，如 urls[0]、items[2].mode
+	label := func(i int) string {
+		if i < len(req.URLs) {
+			return fmt.Sprintf("urls[%d]", i)
+		}
+		return fmt.Sprintf("items[%d]", i-len(req.URLs))
+	}
+
+	items := make([]batchItem, 0, total)
+	sharedErrs := map[string]bool{}
+	for i, raw := range raws {
+		own := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &own); err != nil {
+			errs = append(errs, fieldError{Field: label(i), Message: "必须是对象"})
+			continue
+		}
+		merged := make(map[string]json.RawMessage, len(shared)+len(own))
+		for k, v := range shared {
+			merged[k] = v
+		}
+		for k, v := range own {
+			merged[k] = v
+		}
+		data, _ := json.Marshal(merged)
+
+		r, present, itemErrs := decodeSnifferRequest(data)
+		for _, fe := range itemErrs {
+			// 来自共享参数的错误只报告一次
+			root := fe.Field
+			if n := strings.IndexAny(root, ".["); n >= 0 {
+				root = root[:n]
+			}
+			if _, ok := own[root]; !ok && shared[root] != nil {
+				if !sharedErrs[fe.Field] {
+					sharedErrs[fe.Field] = true
+					errs = append(errs, fieldError{Field: "options." + fe.Field, Message: fe.Message})
+				}
+				continue
+			}
+			switch {
+			case i < len(req.URLs) && fe.Field == "url", fe.Field == "":
+				fe.Field = label(i)
+			default:
+				fe.Field = label(i) + "." + fe.Field
+			}
+			errs = append(errs, fe)
+		}
+		if len(itemErrs) == 0 {
+			items = append(items, batchItem{index: i, req: r, present: present})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, 0, errs
+	}
+
+	deadline := defaultBatchDeadline
+	if req.Deadline > 0 {
+		deadline = time.Duration(req.Deadline) * time.Millisecond
+	}
+	return items, deadline, nil
+}
+
+// runBatch 按嗅探器的并发上限执行条目，每完成一个条目向 results 发送结果，全部结束后关闭 results。
+// 截止时间到达后，运行中的条目返回已收集到的结果，尚未开始的条目不再执行。
+func (s *Server) runBatch(ctx context.Context, items []batchItem, results chan<- batchItemResult) {
+	defer close(results)
+
+	workers := min(len(items), s.sniffer.PoolStats().Capacity)
+	queue := make(chan batchItem)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				results <- s.runBatchItem(ctx, item)
+			}
+		}()
+	}
+	for _, item := range items {
+		queue <- item
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// runBatchItem 嗅探单个条目，截止时间已到时直接返回未执行
+func (s *Server) runBatchItem(ctx context.Context, item batchItem) batchItemResult {
+	result := batchItemResult{Index: item.index, URL: item.req.URL}
+	if err := ctx.Err(); err != nil {
+		result.Code, result.Msg = 408, "已超过截止时间，未执行"
+		return result
+	}
+
+	data, err := s.sniff(ctx, time.Now(), item.req.URL, &item.req.SnifferOptions, item.present, item.req.Cache)
+	if err != nil {
+		result.Code, result.Msg = 500, fmt.Sprintf("初始化嗅探器失败: %v", err)
+		return result
+	}
+	result.Data = data
+	result.Code, _ = data["code"].(int)
+	result.Msg, _ = data["msg"].(string)
+	return result
+}
+
+// handleSnifferBatch 批量嗅探处理器，stream=1 时按完成顺序逐行输出 NDJSON，最后一行为汇总
+func (s *Server) handleSnifferBatch(c *gin.Context) {
+	startTime := time.Now()
+	streamStr := c.DefaultQuery("stream", "0")
+	stream := streamStr == "1" || streamStr == "true"
+
+	body, ok := readRequestBody(c)
+	if !ok {
+		return
+	}
+	items, deadline, errs := parseBatchRequest(body)
+	if len(errs) > 0 {
+		validationFailed(c, errs)
+		return
+	}
+	if err := s.initSniffer(); err != nil {
+		c.JSON(http.StatusInternalServerError, createErrorResponse(fmt.Sprintf("初始化嗅探器失败: %v", err), 500))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), deadline)
+	defer cancel()
+	results := make(chan batchItemResult)
+	go s.runBatch(ctx, items, results)
+
+	summary := batchSummary{Total: len(items)}
+	var collected []batchItemResult
+	var enc *json.Encoder
+	if stream {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		enc = json.NewEncoder(c.Writer)
+	} else {
+		collected = make([]batchItemResult, len(items))
+	}
+
+	// 客户端断开后仍需读完结果，等待所有条目结束
+	for r := range results {
+		if r.Data != nil {
+			summary.Completed++
+		}
+		if r.Code == 200 {
+			summary.Found++
+		}
+		if stream {
+			_ = enc.Encode(r)
+			c.Writer.Flush()
+		} else {
+			collected[r.Index] = r
+		}
+	}
+	summary.DeadlineExceeded = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	summary.Cost = fmt.Sprintf("%d ms", time.Since(startTime).Milliseconds())
+
+	if stream {
+		summary.Done = true
+		_ = enc.Encode(summary)
+		c.Writer.Flush()
+		return
+	}
+	summary.Items = collected
+	c.JSON(http.StatusOK, createResponse(summary, 200, "success"))
+}
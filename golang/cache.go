@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// 缓存控制参数 cache 的取值，为空时优先使用缓存
+const (
+	cacheBypass  = "bypass"  // 不读取也不写入缓存
+	cacheRefresh = "refresh" // 不读取缓存，重新嗅探后写入
+)
+
+// 请求结果中的缓存状态
+const (
+	cacheHit       = "hit"       // 命中缓存
+	cacheMiss      = "miss"      // 未命中，已重新嗅探
+	cacheCoalesced = "coalesced" // 与进行中的相同请求合并，共享其结果
+	cacheBypassed  = "bypass"
+	cacheRefreshed = "refresh"
+)
+
+// 最多缓存的结果数，以及按签名参数计算过期时间时预留的余量
+const (
+	maxCacheEntries = 1000
+	cacheExpiryLead = 30 * time.Second
+)
+
+// cacheEntry 缓存的嗅探结果
+type cacheEntry struct {
+	result  map[string]interface{}
+	expires time.Time
+}
+
+// cacheCall 进行中的嗅探，相同请求等待其结果
+type cacheCall struct {
+	done   chan struct{}
+	result map[string]interface{}
+	cancel context.CancelFunc
+	refs   int // 仍在等待结果的请求数，归零时取消嗅探
+}
+
+// CacheStats 结果缓存统计
+type CacheStats struct {
+	Entries   int     `json:"entries"`
+	InFlight  int     `json:"in_flight"` // 进行中的嗅探数
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Coalesced int64   `json:"coalesced"` // 合并到进行中嗅探的请求数
+	Bypassed  int64   `json:"bypassed"`
+	Refreshed int64   `json:"refreshed"`
+	Evictions int64   `json:"evictions"` // 缓存已满时提前移除的条目数
+	HitRate   float64 `json:"hit_rate"`  // (hits + coalesced) / (hits + coalesced + misses)
+	TTL       string  `json:"ttl"`
+}
+
+// ResultCache 嗅探结果缓存，键为规范化的页面 URL 和生效参数的哈希。
+// 结果中的媒体地址带有签名过期参数时按最早的过期时间失效，否则按 ttl 失效，只缓存嗅探成功的结果。
+type ResultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*cacheEntry
+	calls   map[string]*cacheCall
+	stats   CacheStats
+}
+
+// NewResultCache 创建结果缓存
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		calls:   make(map[string]*cacheCall),
+	}
+}
+
+// validCacheMode 缓存控制参数是否合法
+func validCacheMode(mode string) bool {
+	return mode == "" || mode == cacheBypass || mode == cacheRefresh
+}
+
+// queryCacheMode 读取查询参数 cache，取值不合法时输出 400 响应并返回 false
+func queryCacheMode(c *gin.Context) (string, bool) {
+	mode := c.Query("cache")
+	if !validCacheMode(mode) {
+		c.JSON(http.StatusBadRequest, createErrorResponse(fmt.Sprintf("cache 只能为 %s 或 %s", cacheBypass, cacheRefresh), 400))
+		return "", false
+	}
+	return mode, true
+}
+
+// Do 按缓存控制参数返回缓存的结果或调用 run 嗅探，同时返回缓存状态。
+// 返回的结果是拷贝，调用方可以直接添加字段。rc 为 nil 时直接调用 run，缓存状态为空。
+// 相同请求共享的嗅探在不属于任何请求的上下文中执行，所有等待的请求都离开后才取消；
+// ctx 先于嗅探结束且仍有其他请求等待时返回 nil。
+func (rc *ResultCache) Do(ctx context.Context, pageURL string, options *sniffer.SnifferOptions, mode string, run func(ctx context.Context) map[string]interface{}) (map[string]interface{}, string) {
+	if rc == nil {
+		return run(ctx), ""
+	}
+	if mode == cacheBypass {
+		rc.mu.Lock()
+		rc.stats.Bypassed++
+		rc.mu.Unlock()
+		return run(ctx), cacheBypassed
+	}
+
+	// 需要逐个推送候选地址或过程事件的请求 (SSE、异步任务) 总是重新嗅探，
+	// 不读取缓存也不合并到进行中的嗅探，结果仍会写入缓存
+	live := options.OnCandidate != nil || options.OnEvent != nil
+
+	key := cacheKey(pageURL, options)
+	rc.mu.Lock()
+	if e, ok := rc.entries[key]; ok && mode != cacheRefresh && !live {
+		if time.Now().Before(e.expires) {
+			rc.stats.Hits++
+			rc.mu.Unlock()
+			return copyResult(e.result), cacheHit
+		}
+		delete(rc.entries, key)
+	}
+	// 相同请求正在嗅探时等待其结果，refresh 也会合并，进行中的嗅探本身就是新结果
+	if call, ok := rc.calls[key]; ok && !live {
+		rc.stats.Coalesced++
+		call.refs++
+		rc.mu.Unlock()
+		return rc.wait(ctx, key, call), cacheCoalesced
+	}
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	call := &cacheCall{done: make(chan struct{}), cancel: cancel, refs: 1}
+	if _, ok := rc.calls[key]; !ok {
+		rc.calls[key] = call
+	}
+	status := cacheMiss
+	if mode == cacheRefresh {
+		status = cacheRefreshed
+		rc.stats.Refreshed++
+	} else {
+		rc.stats.Misses++
+	}
+	rc.mu.Unlock()
+
+	go rc.execute(runCtx, key, call, run)
+	return rc.wait(ctx, key, call), status
+}
+
+// execute 执行共享的嗅探，被取消的嗅探结果可能不完整，不写入缓存
+func (rc *ResultCache) execute(ctx context.Context, key string, call *cacheCall, run func(ctx context.Context) map[string]interface{}) {
+	// run 异常退出时也要唤醒等待的请求
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("嗅探过程中发生异常: %v", r)
+			call.result = map[string]interface{}{"code": 500, "msg": fmt.Sprint(r)}
+		}
+		rc.mu.Lock()
+		if rc.calls[key] == call {
+			delete(rc.calls, key)
+		}
+		if code, _ := call.result["code"].(int); code == 200 && ctx.Err() == nil {
+			now := time.Now()
+			if expires := rc.expiry(call.result, now); expires.After(now) {
+				rc.store(key, &cacheEntry{result: call.result, expires: expires})
+			}
+		}
+		rc.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+
+	call.result = run(ctx)
+}
+
+// wait 等待共享的嗅探结束并返回结果的拷贝。ctx 先结束时退出等待，
+// 最后一个退出的请求取消嗅探并取回取消前收集到的结果，其余请求返回 nil
+func (rc *ResultCache) wait(ctx context.Context, key string, call *cacheCall) map[string]interface{} {
+	select {
+	case <-call.done:
+		return copyResult(call.result)
+	case <-ctx.Done():
+	}
+
+	rc.mu.Lock()
+	call.refs--
+	last := call.refs == 0
+	if last && rc.calls[key] == call {
+		// 之后的相同请求重新嗅探，不再合并到已取消的嗅探
+		delete(rc.calls, key)
+	}
+	rc.mu.Unlock()
+
+	if last {
+		call.cancel()
+		<-call.done
+		return copyResult(call.result)
+	}
+	select {
+	case <-call.done:
+		return copyResult(call.result)
+	default:
+		return nil
+	}
+}
+
+// store 保存结果，缓存已满时先清理过期条目，仍然已满时移除最早过期的条目。调用方需持有锁
+func (rc *ResultCache) store(key string, e *cacheEntry) {
+	if _, ok := rc.entries[key]; !ok && len(rc.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, old := range rc.entries {
+			if !now.Before(old.expires) {
+				delete(rc.entries, k)
+			}
+		}
+		for len(rc.entries) >= maxCacheEntries {
+			var oldest string
+			for k, old := range rc.entries {
+				if oldest == "" || old.expires.Before(rc.entries[oldest].expires) {
+					oldest = k
+				}
+			}
+			delete(rc.entries, oldest)
+			rc.stats.Evictions++
+		}
+	}
+	rc.entries[key] = e
+}
+
+// expiry 计算结果的过期时间，媒体地址带有签名过期参数时取最早的过期时间并预留余量
+func (rc *ResultCache) expiry(result map[string]interface{}, now time.Time) time.Time {
+	var earliest time.Time
+	for _, u := range resultURLs(result) {
+		if t, ok := signedExpiry(u, now); ok && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	if earliest.IsZero() {
+		return now.Add(rc.ttl)
+	}
+	return earliest.Add(-cacheExpiryLead)
+}
+
+// Stats 返回缓存统计
+func (rc *ResultCache) Stats() CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	st := rc.stats
+	st.Entries = len(rc.entries)
+	st.InFlight = len(rc.calls)
+	st.TTL = rc.ttl.String()
+	if total := st.Hits + st.Coalesced + st.Misses; total > 0 {
+		st.HitRate = float64(st.Hits+st.Coalesced) / float64(total)
+	}
+	return st
+}
+
+// Clear 清空缓存的结果，统计和进行中的嗅探不受影响
+func (rc *ResultCache) Clear() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	n := len(rc.entries)
+	rc.entries = make(map[string]*cacheEntry)
+	return n
+}
+
+// cacheKeyFields 参与缓存键计算的嗅探参数，只包含影响嗅探结果的字段，
+// timeout 等只影响耗时的字段不参与计算，回调等不可序列化的字段也不参与
+type cacheKeyFields struct {
+	Mode           int
+	CustomRegex    string
+	SnifferExclude string
+	CSS            string
+	IsPc           *bool
+	Device         string
+	Proxy          string
+	Cookies        []sniffer.Cookie
+	Session        string
+	Incognito      bool
+	ScanBody       bool
+	ValidateHLS    bool
+	ParseDASH      bool
+	HeadProbe      bool
+	CaptureMSE     bool
+	NoFilter       bool
+	FilterRules    []string
+	Headers        map[string]string
+	Script         string
+	InitScript     string
+	Actions        []sniffer.Action
+	Frame          string
+}
+
+// cacheKey 由规范化的页面 URL 和影响结果的参数的哈希组成
+func cacheKey(pageURL string, options *sniffer.SnifferOptions) string {
+	data, _ := json.Marshal(cacheKeyFields{
+		Mode:           options.Mode,
+		CustomRegex:    options.CustomRegex,
+		SnifferExclude: options.SnifferExclude,
+		CSS:            options.CSS,
+		IsPc:           options.IsPc,
+		Device:         options.Device,
+		Proxy:          options.Proxy,
+		Cookies:        options.Cookies,
+		Session:        options.Session,
+		Incognito:      options.Incognito,
+		ScanBody:       options.ScanBody,
+		ValidateHLS:    options.ValidateHLS,
+		ParseDASH:      options.ParseDASH,
+		HeadProbe:      options.HeadProbe,
+		CaptureMSE:     options.CaptureMSE,
+		NoFilter:       options.NoFilter,
+		FilterRules:    options.FilterRules,
+		Headers:        options.Headers,
+		Script:         options.Script,
+		InitScript:     options.InitScript,
+		Actions:        options.Actions,
+		Frame:          options.Frame,
+	})
+	sum := sha256.Sum256(data)
+	return normalizeURL(pageURL) + "#" + hex.EncodeToString(sum[:])
+}
+
+// normalizeURL 规范化 URL：协议和域名小写，去掉默认端口和锚点，查询参数按名称排序
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+// resultURLs 提取结果中的媒体地址，mode 0 为 url，mode 1 为 urls 中的各个地址
+func resultURLs(result map[string]interface{}) []string {
+	var urls []string
+	if u, ok := result["url"].(string); ok && u != "" {
+		urls = append(urls, u)
+	}
+	if list, ok := result["urls"].([]interface{}); ok {
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				if u, ok := m["url"].(string); ok && u != "" {
+					urls = append(urls, u)
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// signedExpiry 解析签名地址中的过期时间，支持常见 CDN 和对象存储的签名参数：
+// expires / expire / exp / e / deadline / x-expires (Unix 时间戳，秒或毫秒)、
+// X-Amz-Date + X-Amz-Expires、X-Goog-Date + X-Goog-Expires、auth_key (阿里云 A 鉴权) 和 txTime (腾讯云，十六进制)。
+// 解析出的时间早于一天前或晚于一年后时视为无效。
+func signedExpiry(rawURL string, now time.Time) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	query := make(map[string]string)
+	for k, v := range u.Query() {
+		if len(v) > 0 {
+			query[strings.ToLower(k)] = v[0]
+		}
+	}
+
+	var t time.Time
+	switch {
+	case query["x-amz-date"] != "" && query["x-amz-expires"] != "":
+		t = dateExpiry(query["x-amz-date"], query["x-amz-expires"])
+	case query["x-goog-date"] != "" && query["x-goog-expires"] != "":
+		t = dateExpiry(query["x-goog-date"], query["x-goog-expires"])
+	case query["auth_key"] != "":
+		t = unixExpiry(strings.SplitN(query["auth_key"], "-", 2)[0])
+	case query["txtime"] != "":
+		if n, err := strconv.ParseInt(query["txtime"], 16, 64); err == nil {
+			t = time.Unix(n, 0)
+		}
+	default:
+		for _, name := range []string{"expires", "expire", "exp", "e", "deadline", "x-expires"} {
+			if v := query[name]; v != "" {
+				if t = unixExpiry(v); !t.IsZero() {
+					break
+				}
+			}
+		}
+	}
+
+	if t.IsZero() || t.Before(now.Add(-24*time.Hour)) || t.After(now.AddDate(1, 0, 0)) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// unixExpiry 解析秒或毫秒级 Unix 时间戳
+func unixExpiry(v string) time.Time {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	if n > 1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// dateExpiry 解析签名时间 (20060102T150405Z) 加有效秒数
+func dateExpiry(date, seconds string) time.Time {
+	signed, err := time.Parse("20060102T150405Z", date)
+	if err != nil {
+		return time.Time{}
+	}
+	n, err := strconv.Atoi(seconds)
+	if err != nil {
+		return time.Time{}
+	}
+	return signed.Add(time.Duration(n) * time.Second)
+}
+
+// copyResult 浅拷贝结果，嵌套的值在缓存后不再修改
+func copyResult(result map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		copied[k] = v
+	}
+	return copied
+}
+
+// handleCache 结果缓存统计处理器
+func (s *Server) handleCache(c *gin.Context) {
+	if s.cache == nil {
+		c.JSON(http.StatusNotFound, createErrorResponse("未启用结果缓存", 404))
+		return
+	}
+	c.JSON(http.StatusOK, createResponse(s.cache.Stats(), 200, "success"))
+}
+
+// handleClearCache 清空结果缓存
+func (s *Server) handleClearCache(c *gin.Context) {
+	if s.cache == nil {
+		c.JSON(http.StatusNotFound, createErrorResponse("未启用结果缓存", 404))
+		return
+	}
+	n := s.cache.Clear()
+	c.JSON(http.StatusOK, createResponse(gin.H{"cleared": n}, 200, "success"))
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// 任务类型
+const (
+	jobTypeSniffer = "sniffer"
+	jobTypeFetCode = "fetCodeByWebView"
+)
+
+// 任务状态
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+// 默认的任务结果保留时间和最多保留的任务数
+const (
+	defaultJobTTL = 10 * time.Minute
+	maxJobs       = 1000
+)
+
+// job 异步嗅探任务
+type job struct {
+	id        string
+	typ       string
+	url       string
+	createdAt time.Time
+	cancel    context.CancelFunc
+
+	mu         sync.Mutex
+	status     string
+	finishedAt time.Time
+	candidates []sniffer.URLWithHeaders
+	result     map[string]interface{}
+	err        string
+}
+
+// jobView 任务状态响应
+type jobView struct {
+	ID         string                   `json:"id"`
+	Type       string                   `json:"type"`
+	URL        string                   `json:"url"`
+	Status     string                   `json:"status"`
+	CreatedAt  string                   `json:"created_at"`
+	FinishedAt string                   `json:"finished_at,omitempty"`
+	Cost       string                   `json:"cost"`
+	Candidates []sniffer.URLWithHeaders `json:"candidates,omitempty"` // 已收集到的候选地址，嗅探结束前即可获取
+	Result     map[string]interface{}   `json:"result,omitempty"`     // 与同步接口 data 相同的结果
+	Error      string                   `json:"error,omitempty"`
+}
+
+// view 返回任务当前状态
+func (j *job) view() jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	v := jobView{
+		ID:         j.id,
+		Type:       j.typ,
+		URL:        j.url,
+		Status:     j.status,
+		CreatedAt:  j.createdAt.Format(time.RFC3339),
+		Candidates: append([]sniffer.URLWithHeaders(nil), j.candidates...),
+		Result:     j.result,
+		Error:      j.err,
+	}
+	end := time.Now()
+	if !j.finishedAt.IsZero() {
+		end = j.finishedAt
+		v.FinishedAt = j.finishedAt.Format(time.RFC3339)
+	}
+	v.Cost = fmt.Sprintf("%d ms", end.Sub(j.createdAt).Milliseconds())
+	return v
+}
+
+// addCandidate 记录嗅探过程中发现的候选地址
+func (j *job) addCandidate(u sniffer.URLWithHeaders) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.candidates = append(j.candidates, u)
+}
+
+// finish 记录任务结果，已取消的任务保持取消状态，但保留取消前收集到的结果
+func (j *job) finish(result map[string]interface{}, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.result = result
+	if err != nil {
+		j.err = fmt.Sprintf("初始化嗅探器失败: %v", err)
+	}
+	if j.status == jobRunning {
+		j.status = jobDone
+		if err != nil {
+			j.status = jobFailed
+		}
+		j.finishedAt = time.Now()
+	}
+}
+
+// stop 取消运行中的任务，返回任务是否仍在运行
+func (j *job) stop() bool {
+	j.mu.Lock()
+	running := j.status == jobRunning
+	if running {
+		j.status = jobCanceled
+		j.finishedAt = time.Now()
+	}
+	j.mu.Unlock()
+
+	if running {
+		j.cancel()
+	}
+	return running
+}
+
+// expired 任务是否已结束且超过保留时间
+func (j *job) expired(ttl time.Duration, now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status != jobRunning && now.Sub(j.finishedAt) > ttl
+}
+
+// JobStore 异步任务表，已结束的任务保留 ttl 后删除
+type JobStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	jobs map[string]*job
+}
+
+// NewJobStore 创建任务表，ttl 不大于 0 时使用默认保留时间
+func NewJobStore(ttl time.Duration) *JobStore {
+	if ttl <= 0 {
+		ttl = defaultJobTTL
+	}
+	return &JobStore{ttl: ttl, jobs: make(map[string]*job)}
+}
+
+// add 登记新任务，任务数达到上限时返回 false
+func (js *JobStore) add(j *job) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.sweep()
+	if len(js.jobs) >= maxJobs {
+		return false
+	}
+	js.jobs[j.id] = j
+	return true
+}
+
+// get 查找任务
+func (js *JobStore) get(id string) (*job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.sweep()
+	j, ok := js.jobs[id]
+	return j, ok
+}
+
+// remove 删除任务
+func (js *JobStore) remove(id string) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	delete(js.jobs, id)
+}
+
+// sweep 删除过期的任务，调用方需持有锁
+func (js *JobStore) sweep() {
+	now := time.Now()
+	for id, j := range js.jobs {
+		if j.expired(js.ttl, now) {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// newJobID 生成随机任务 ID
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleCreateJob 创建异步任务处理器，请求体与 POST /sniffer 相同，查询参数 type 指定任务类型
+func (s *Server) handleCreateJob(c *gin.Context) {
+	startTime := time.Now()
+	typ := c.DefaultQuery("type", jobTypeSniffer)
+	if typ != jobTypeSniffer && typ != jobTypeFetCode {
+		c.JSON(http.StatusBadRequest, createErrorResponse(fmt.Sprintf("未知的任务类型: %s", typ), 400))
+		return
+	}
+	req, present, ok := bindSnifferRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:        newJobID(),
+		typ:       typ,
+		url:       req.URL,
+		createdAt: startTime,
+		cancel:    cancel,
+		status:    jobRunning,
+	}
+	if !s.jobs.add(j) {
+		cancel()
+		c.JSON(http.StatusTooManyRequests, createErrorResponse("任务数已达上限", 429))
+		return
+	}
+
+	options := &req.SnifferOptions
+	go func() {
+		defer cancel()
+		var result map[string]interface{}
+		var err error
+		if typ == jobTypeSniffer {
+			options.OnCandidate = j.addCandidate
+			result, err = s.sniff(ctx, startTime, req.URL, options, present, req.Cache)
+		} else {
+			result, err = s.fetCode(ctx, startTime, req.URL, options, present)
+		}
+		j.finish(result, err)
+	}()
+
+	c.JSON(http.StatusAccepted, createResponse(j.view(), 202, "任务已创建"))
+}
+
+// handleGetJob 任务状态处理器，运行中的嗅探任务返回已收集到的候选地址
+func (s *Server) handleGetJob(c *gin.Context) {
+	j, ok := s.jobs.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, createErrorResponse("任务不存在或已过期", 404))
+		return
+	}
+	c.JSON(http.StatusOK, createResponse(j.view(), 200, "success"))
+}
+
+// handleDeleteJob 取消运行中的任务并关闭页面，已结束的任务直接删除
+func (s *Server) handleDeleteJob(c *gin.Context) {
+	j, ok := s.jobs.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, createErrorResponse("任务不存在或已过期", 404))
+		return
+	}
+	if j.stop() {
+		c.JSON(http.StatusOK, createResponse(j.view(), 200, "任务已取消"))
+		return
+	}
+	s.jobs.remove(j.id)
+	c.JSON(http.StatusOK, createResponse(j.view(), 200, "任务已删除"))
+}
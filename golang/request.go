@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// 请求体最大长度
+const maxRequestBody = 4 << 20
+
+// snifferRequest POST /sniffer 和 /fetCodeByWebView 的请求体，字段与 SnifferOptions 一致。
+// 与 GET 参数不同，script、init_script 为原始文本，headers 为对象，cookies 为对象数组。
+type snifferRequest struct {
+	URL   string `json:"url"`
+	Cache string `json:"cache"` // 缓存控制参数，仅对嗅探生效
+	sniffer.SnifferOptions
+}
+
+// fieldError 请求体字段校验错误
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// handleSnifferJSON JSON 请求体的嗅探处理器
+func (s *Server) handleSnifferJSON(c *gin.Context) {
+	startTime := time.Now()
+	req, present, ok := bindSnifferRequest(c)
+	if !ok {
+		return
+	}
+	s.runSniffer(c, startTime, req.URL, &req.SnifferOptions, present, req.Cache)
+}
+
+// handleFetCodeJSON JSON 请求体的页面源码处理器
+func (s *Server) handleFetCodeJSON(c *gin.Context) {
+	startTime := time.Now()
+	req, present, ok := bindSnifferRequest(c)
+	if !ok {
+		return
+	}
+	s.runFetCode(c, startTime, req.URL, &req.SnifferOptions, present)
+}
+
+// bindSnifferRequest 解析并校验请求体，失败时输出 400 响应和字段错误列表
+func bindSnifferRequest(c *gin.Context) (*snifferRequest, func(key string) bool, bool) {
+	body, ok := readRequestBody(c)
+	if !ok {
+		return nil, nil, false
+	}
+	req, present, errs := decodeSnifferRequest(body)
+	if len(errs) > 0 {
+		validationFailed(c, errs)
+		return nil, nil, false
+	}
+	return req, present, true
+}
+
+// readRequestBody 读取请求体，失败或超过长度限制时输出错误响应
+func readRequestBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRequestBody+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, createErrorResponse(fmt.Sprintf("读取请求体失败: %v", err), 400))
+		return nil, false
+	}
+	if len(body) > maxRequestBody {
+		c.JSON(http.StatusRequestEntityTooLarge, createErrorResponse("请求体过大", 413))
+		return nil, false
+	}
+	return body, true
+}
+
+// decodeSnifferRequest 解析并校验 JSON 请求体，返回请求、判断字段是否出现的函数和字段错误列表
+func decodeSnifferRequest(body []byte) (*snifferRequest, func(key string) bool, []fieldError) {
+	req := &snifferRequest{}
+	if err := decodeStrict(body, req); err != nil {
+		return nil, nil, []fieldError{decodeError(err)}
+	}
+
+	// 记录请求体中出现的字段，站点规则只填充未出现的字段
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+	present := func(key string) bool {
+		_, ok := fields[key]
+		return ok
+	}
+	if !present("timeout") {
+		req.Timeout = 10000
+	}
+
+	if errs := validateSnifferRequest(req); len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	// 与 GET 接口一致，请求头名称统一为小写
+	if len(req.Headers) > 0 {
+		headers := make(map[string]string, len(req.Headers))
+		for k, v := range req.Headers {
+			headers[strings.ToLower(strings.TrimSpace(k))] = v
+		}
+		req.Headers = headers
+	}
+	return req, present, nil
+}
+
+// decodeStrict 解析 JSON，出现未知字段时返回错误
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// validationFailed 输出参数校验失败响应
+func validationFailed(c *gin.Context, errs []fieldError) {
+	msg := "参数校验失败"
+	switch {
+	case len(errs) == 1 && errs[0].Field == "":
+		msg = fmt.Sprintf("%s: %s", msg, errs[0].Message)
+	case len(errs) == 1:
+		msg = fmt.Sprintf("%s: %s %s", msg, errs[0].Field, errs[0].Message)
+	}
+	c.JSON(http.StatusBadRequest, createResponse(gin.H{"errors": errs}, 400, msg))
+}
+
+// decodeError 将 JSON 解码错误转换为字段错误
+func decodeError(err error) fieldError {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fieldError{Field: typeErr.Field, Message: fmt.Sprintf("类型错误，应为 %s", jsonTypeName(typeErr.Type.Kind().String()))}
+	case errors.As(err, &syntaxErr):
+		return fieldError{Field: "", Message: fmt.Sprintf("JSON 格式错误 (位置 %d): %v", syntaxErr.Offset, err)}
+	case errors.Is(err, io.EOF):
+		return fieldError{Field: "", Message: "请求体为空"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fieldError{Field: "", Message: "JSON 格式错误: 请求体不完整"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fieldError{Field: strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), Message: "未知字段"}
+	}
+	return fieldError{Field: "", Message: err.Error()}
+}
+
+// jsonTypeName Go 类型对应的 JSON 类型名称
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "字符串"
+	case "bool":
+		return "布尔值"
+	case "int", "int64", "float64":
+		return "数字"
+	case "slice":
+		return "数组"
+	case "map", "struct":
+		return "对象"
+	}
+	return kind
+}
+
+// validateSnifferRequest 校验请求体字段，返回全部字段错误
+func validateSnifferRequest(req *snifferRequest) []fieldError {
+	var errs []fieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case req.URL == "":
+		add("url", "不能为空")
+	case !isValidURL(req.URL):
+		add("url", "必须是 http:// 或 https:// 开头的地址")
+	}
+	if !validCacheMode(req.Cache) {
+		add("cache", "只能为 %s 或 %s", cacheBypass, cacheRefresh)
+	}
+	if req.Mode != 0 && req.Mode != 1 {
+		add("mode", "只能为 0 或 1")
+	}
+	if req.Timeout < 0 || req.Timeout > 60000 {
+		add("timeout", "必须在 0 到 60000 之间")
+	}
+	if req.Device != "" {
+		if _, ok := sniffer.LookupDevice(req.Device); !ok {
+			add("device", "未知设备: %s", req.Device)
+		}
+	}
+	if req.Proxy != "" && req.Proxy != sniffer.ProxyDirect {
+		if _, err := sniffer.ParseProxy(req.Proxy); err != nil {
+			add("proxy", "%s", optionMessage(err))
+		}
+	}
+	if req.Session != "" && req.Incognito {
+		add("incognito", "不能与 session 同时使用")
+	}
+	for _, f := range []struct{ field, expr string }{
+		{"custom_regex", req.CustomRegex},
+		{"sniffer_exclude", req.SnifferExclude},
+		{"frame", req.Frame},
+	} {
+		if f.expr == "" {
+			continue
+		}
+		if _, err := regexp.Compile(f.expr); err != nil {
+			add(f.field, "正则格式错误: %v", err)
+		}
+	}
+	for i, cookie := range req.Cookies {
+		if cookie.Name == "" {
+			add(fmt.Sprintf("cookies[%d].name", i), "不能为空")
+		}
+	}
+	actionsValid := true
+	for i, action := range req.Actions {
+		if err := action.Validate(); err != nil {
+			add(fmt.Sprintf("actions[%d]", i), "%v", err)
+			actionsValid = false
+		}
+	}
+	if err := sniffer.ValidateActions(req.Actions); err != nil && actionsValid {
+		add("actions", "%s", optionMessage(err))
+	}
+	return errs
+}
+
+// optionMessage 去掉 ErrInvalidOption 前缀，只保留具体原因
+func optionMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), sniffer.ErrInvalidOption.Error()+": ")
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// SiteRule 站点规则，为匹配的域名提供默认嗅探参数，请求中显式传入的参数优先
+type SiteRule struct {
+	Name           string            `json:"name"`
+	Hosts          []string          `json:"hosts"` // 域名模式，example.com 匹配该域名及其子域名，支持 * 通配符
+	Mode           *int              `json:"mode,omitempty"`
+	Timeout        int               `json:"timeout,omitempty"`
+	CustomRegex    string            `json:"custom_regex,omitempty"`
+	SnifferExclude string            `json:"sniffer_exclude,omitempty"`
+	CSS            string            `json:"css,omitempty"`
+	Frame          string            `json:"frame,omitempty"`
+	Script         string            `json:"script,omitempty"`      // 原始脚本，不需要 Base64 编码
+	InitScript     string            `json:"init_script,omitempty"` // 原始脚本，不需要 Base64 编码
+	Device         string            `json:"device,omitempty"`
+	IsPc           *bool             `json:"is_pc,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Actions        []sniffer.Action  `json:"actions,omitempty"`
+}
+
+// matchHost 规则是否匹配域名
+func (r *SiteRule) matchHost(host string) bool {
+	for _, pattern := range r.Hosts {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.Contains(pattern, "*") {
+			if ok, _ := path.Match(pattern, host); ok {
+				return true
+			}
+			continue
+		}
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleSet 站点规则文件，文件修改后自动重新加载
+type RuleSet struct {
+	mu        sync.Mutex
+	path      string
+	modTime   time.Time
+	lastCheck time.Time
+	rules     []SiteRule
+}
+
+// 规则文件修改检查间隔
+const ruleCheckInterval = time.Second
+
+// LoadRuleSet 加载规则文件，文件为 JSON 数组或 {"rules": [...]}
+func LoadRuleSet(path string) (*RuleSet, error) {
+	rs := &RuleSet{path: path}
+	if err := rs.reload(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// reload 读取并解析规则文件
+func (rs *RuleSet) reload() error {
+	st, err := os.Stat(rs.path)
+	if err != nil {
+		return fmt.Errorf("读取规则文件失败: %v", err)
+	}
+	data, err := os.ReadFile(rs.path)
+	if err != nil {
+		return fmt.Errorf("读取规则文件失败: %v", err)
+	}
+
+	var rules []SiteRule
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &rules)
+	} else {
+		var file struct {
+			Rules []SiteRule `json:"rules"`
+		}
+		err = json.Unmarshal(data, &file)
+		rules = file.Rules
+	}
+	if err != nil {
+		return fmt.Errorf("规则文件格式错误: %v", err)
+	}
+	for i, r := range rules {
+		if len(r.Hosts) == 0 {
+			return fmt.Errorf("第 %d 条规则缺少 hosts", i+1)
+		}
+		if err := sniffer.ValidateActions(r.Actions); err != nil {
+			return fmt.Errorf("规则 %s: %v", r.Name, err)
+		}
+		if r.Device != "" {
+			if _, ok := sniffer.LookupDevice(r.Device); !ok {
+				return fmt.Errorf("规则 %s: 未知设备 %s", r.Name, r.Device)
+			}
+		}
+		// 正则错误会导致嗅探失败或被静默忽略，整个文件拒绝加载
+		for _, f := range []struct{ field, expr string }{
+			{"custom_regex", r.CustomRegex},
+			{"sniffer_exclude", r.SnifferExclude},
+			{"frame", r.Frame},
+		} {
+			if f.expr == "" {
+				continue
+			}
+			if _, err := regexp.Compile(f.expr); err != nil {
+				return fmt.Errorf("规则 %s: %s 正则格式错误: %v", r.Name, f.field, err)
+			}
+		}
+	}
+
+	rs.rules = rules
+	rs.modTime = st.ModTime()
+	return nil
+}
+
+// checkReload 文件修改时间变化时重新加载，加载失败时保留旧规则
+func (rs *RuleSet) checkReload() {
+	if time.Since(rs.lastCheck) < ruleCheckInterval {
+		return
+	}
+	rs.lastCheck = time.Now()
+
+	st, err := os.Stat(rs.path)
+	if err != nil || st.ModTime().Equal(rs.modTime) {
+		return
+	}
+	if err := rs.reload(); err != nil {
+		log.Printf("重新加载规则文件失败，继续使用旧规则: %v", err)
+		return
+	}
+	log.Printf("规则文件已重新加载: %d 条规则", len(rs.rules))
+}
+
+// Match 按文件顺序返回第一条匹配页面 URL 的规则
+func (rs *RuleSet) Match(pageURL string) (*SiteRule, bool) {
+	if rs == nil {
+		return nil, false
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.checkReload()
+	for i := range rs.rules {
+		if rs.rules[i].matchHost(host) {
+			rule := rs.rules[i]
+			return &rule, true
+		}
+	}
+	return nil, false
+}
+
+// Len 返回规则数
+func (rs *RuleSet) Len() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return len(rs.rules)
+}
+
+// queryPresent 判断查询参数是否传入，查询参数在调用时复制，返回的函数可以在处理器返回后使用
+func queryPresent(c *gin.Context) func(key string) bool {
+	query := c.Request.URL.Query()
+	return func(key string) bool {
+		_, ok := query[key]
+		return ok
+	}
+}
+
+// applyRule 将匹配的站点规则合并到嗅探选项，只填充请求中未传入的参数，返回匹配的规则名称
+func (s *Server) applyRule(pageURL string, options *sniffer.SnifferOptions, present func(key string) bool) string {
+	rule, ok := s.rules.Match(pageURL)
+	if !ok {
+		return ""
+	}
+	absent := func(key string) bool { return !present(key) }
+
+	if rule.Mode != nil && absent("mode") {
+		options.Mode = *rule.Mode
+	}
+	if rule.Timeout > 0 && absent("timeout") {
+		options.Timeout = min(rule.Timeout, 60000) // 最大 60 秒
+	}
+	if rule.CustomRegex != "" && absent("custom_regex") {
+		options.CustomRegex = rule.CustomRegex
+	}
+	if rule.SnifferExclude != "" && absent("sniffer_exclude") {
+		options.SnifferExclude = rule.SnifferExclude
+	}
+	if rule.CSS != "" && absent("css") {
+		options.CSS = rule.CSS
+	}
+	if rule.Frame != "" && absent("frame") {
+		options.Frame = rule.Frame
+	}
+	if rule.Script != "" && absent("script") {
+		options.Script = rule.Script
+	}
+	if rule.InitScript != "" && absent("init_script") {
+		options.InitScript = rule.InitScript
+	}
+	if rule.Device != "" && absent("device") && absent("is_pc") {
+		options.Device = rule.Device
+	}
+	if rule.IsPc != nil && absent("is_pc") {
+		options.IsPc = rule.IsPc
+	}
+	if len(rule.Actions) > 0 && absent("actions") {
+		options.Actions = rule.Actions
+	}
+	// 请求头按键合并，请求中的同名请求头优先
+	if len(rule.Headers) > 0 {
+		headers := make(map[string]string, len(rule.Headers)+len(options.Headers))
+		// 与请求参数一致，规则中的请求头名称统一为小写后再合并
+		for k, v := range rule.Headers {
+			headers[strings.ToLower(strings.TrimSpace(k))] = v
+		}
+		for k, v := range options.Headers {
+			headers[k] = v
+		}
+		options.Headers = headers
+	}
+	return rule.Name
+}
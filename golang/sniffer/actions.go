@@ -0,0 +1,220 @@
+package sniffer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+)
+
+// 页面交互步骤类型
+const (
+	ActionClick    = "click"     // 点击元素
+	ActionWait     = "wait"      // 等待元素出现
+	ActionWaitIdle = "wait_idle" // 等待网络空闲
+	ActionScroll   = "scroll"    // 滚动到元素或按偏移滚动
+	ActionType     = "type"      // 向元素输入文本
+	ActionPress    = "press"     // 按键
+	ActionSleep    = "sleep"     // 固定等待
+	ActionFrame    = "frame"     // 切换到 iframe，selector 为空时切回主页面
+)
+
+// 交互步骤默认值
+const (
+	actionTimeout  = 5 * time.Second        // 单步默认超时
+	actionIdleTime = 500 * time.Millisecond // wait_idle 默认空闲时长
+	maxActions     = 50
+)
+
+// Action 页面交互步骤，在页面加载完成后、执行 Script 之前按顺序执行
+type Action struct {
+	Type     string  `json:"type"`
+	Selector string  `json:"selector,omitempty"` // CSS 选择器，在当前 frame 中查找
+	Text     string  `json:"text,omitempty"`     // type 输入的文本
+	Key      string  `json:"key,omitempty"`      // press 的按键，如 Enter、Escape、ArrowDown 或单个字符
+	X        float64 `json:"x,omitempty"`        // scroll 的水平偏移
+	Y        float64 `json:"y,omitempty"`        // scroll 的垂直偏移，与 X 都为 0 且没有 selector 时滚动一屏
+	Duration int     `json:"duration,omitempty"` // sleep 的时长或 wait_idle 的空闲时长，单位毫秒
+	Timeout  int     `json:"timeout,omitempty"`  // 单步超时，单位毫秒，默认 5000
+	Optional bool    `json:"optional,omitempty"` // 失败时继续执行后续步骤
+}
+
+// ActionResult 交互步骤执行结果
+type ActionResult struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Cost  string `json:"cost"`
+}
+
+// actionKeys 按键名称
+var actionKeys = map[string]input.Key{
+	"enter":      input.Enter,
+	"escape":     input.Escape,
+	"esc":        input.Escape,
+	"tab":        input.Tab,
+	"space":      input.Space,
+	"backspace":  input.Backspace,
+	"delete":     input.Delete,
+	"arrowup":    input.ArrowUp,
+	"arrowdown":  input.ArrowDown,
+	"arrowleft":  input.ArrowLeft,
+	"arrowright": input.ArrowRight,
+	"pageup":     input.PageUp,
+	"pagedown":   input.PageDown,
+	"home":       input.Home,
+	"end":        input.End,
+	"f11":        input.F11,
+}
+
+// actionKey 解析按键名称，支持 actionKeys 中的名称和单个可打印字符
+func actionKey(name string) (input.Key, bool) {
+	if k, ok := actionKeys[strings.ToLower(name)]; ok {
+		return k, true
+	}
+	if len(name) == 1 && name[0] > ' ' && name[0] < 0x7f {
+		return input.Key(name[0]), true
+	}
+	return 0, false
+}
+
+// ParseActions 解析 JSON 数组格式的交互步骤并校验参数
+func ParseActions(raw string) ([]Action, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var actions []Action
+	if err := json.Unmarshal([]byte(raw), &actions); err != nil {
+		return nil, fmt.Errorf("%w: actions JSON 格式错误: %v", ErrInvalidOption, err)
+	}
+	if err := ValidateActions(actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// ValidateActions 校验交互步骤
+func ValidateActions(actions []Action) error {
+	if len(actions) > maxActions {
+		return fmt.Errorf("%w: 交互步骤不能超过 %d 个", ErrInvalidOption, maxActions)
+	}
+	for i, a := range actions {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("%w: 第 %d 个交互步骤: %v", ErrInvalidOption, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Validate 校验单个交互步骤的参数
+func (a Action) Validate() error {
+	switch a.Type {
+	case ActionClick, ActionWait, ActionType:
+		if a.Selector == "" {
+			return errors.New("缺少 selector")
+		}
+	case ActionPress:
+		if _, ok := actionKey(a.Key); !ok {
+			return fmt.Errorf("不支持的按键 %q", a.Key)
+		}
+	case ActionSleep:
+		if a.Duration <= 0 {
+			return errors.New("缺少 duration")
+		}
+	case ActionWaitIdle, ActionScroll, ActionFrame:
+	default:
+		return fmt.Errorf("未知的步骤类型 %q", a.Type)
+	}
+	return nil
+}
+
+// runActions 按顺序执行交互步骤，失败的步骤会中止后续步骤，标记 optional 的步骤除外
+func (s *Sniffer) runActions(ctx context.Context, page *rod.Page, actions []Action) []ActionResult {
+	if len(actions) == 0 {
+		return nil
+	}
+	results := make([]ActionResult, 0, len(actions))
+	frame := page
+	for i, a := range actions {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		err := s.runAction(ctx, page, &frame, a)
+		result := ActionResult{Index: i, Type: a.Type, OK: err == nil, Cost: costString(start)}
+		if err != nil {
+			result.Error = err.Error()
+			s.log("交互步骤执行失败:", i, a.Type, a.Selector, err)
+		}
+		results = append(results, result)
+		if err != nil && !a.Optional {
+			break
+		}
+	}
+	return results
+}
+
+// runAction 执行单个交互步骤，frame 为当前所在的 frame，切换 iframe 时会被修改
+func (s *Sniffer) runAction(ctx context.Context, page *rod.Page, frame **rod.Page, a Action) error {
+	timeout := actionTimeout
+	if a.Timeout > 0 {
+		timeout = time.Duration(a.Timeout) * time.Millisecond
+	}
+	if a.Type == ActionSleep {
+		timeout = time.Duration(a.Duration) * time.Millisecond
+	}
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	p := (*frame).Context(stepCtx)
+
+	return rod.Try(func() {
+		switch a.Type {
+		case ActionClick:
+			p.MustElement(a.Selector).MustClick()
+		case ActionWait:
+			p.MustElement(a.Selector).MustWaitVisible()
+		case ActionWaitIdle:
+			idle := actionIdleTime
+			if a.Duration > 0 {
+				idle = time.Duration(a.Duration) * time.Millisecond
+			}
+			p.WaitRequestIdle(idle, nil, nil, nil)()
+			if stepCtx.Err() != nil {
+				panic(fmt.Errorf("等待网络空闲超时"))
+			}
+		case ActionScroll:
+			if a.Selector != "" {
+				p.MustElement(a.Selector).MustScrollIntoView()
+			} else {
+				p.MustEval(`(x, y) => window.scrollBy(x, y || (x ? 0 : window.innerHeight))`, a.X, a.Y)
+			}
+		case ActionType:
+			p.MustElement(a.Selector).MustInput(a.Text)
+		case ActionPress:
+			key, _ := actionKey(a.Key)
+			if a.Selector != "" {
+				p.MustElement(a.Selector).MustType(key)
+			} else {
+				p.Keyboard.MustType(key)
+			}
+		case ActionSleep:
+			<-stepCtx.Done()
+			if ctx.Err() != nil {
+				panic(ctx.Err())
+			}
+		case ActionFrame:
+			if a.Selector == "" {
+				*frame = page
+				return
+			}
+			*frame = p.MustElement(a.Selector).MustFrame()
+		}
+	})
+}
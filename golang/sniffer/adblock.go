@@ -0,0 +1,528 @@
+package sniffer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// filterTypes AdBlock 资源类型与浏览器资源类型的对应关系
+var filterTypes = map[string][]string{
+	"script":         {"Script"},
+	"image":          {"Image"},
+	"stylesheet":     {"Stylesheet"},
+	"object":         {"Other"},
+	"xmlhttprequest": {"XHR", "Fetch"},
+	"subdocument":    {"Document"},
+	"document":       {"Document"},
+	"media":          {"Media"},
+	"font":           {"Font"},
+	"websocket":      {"WebSocket"},
+	"ping":           {"Ping", "CSPViolationReport"},
+	"other":          {"Other", "Manifest", "EventSource", "Prefetch", "TextTrack"},
+}
+
+// filterRequest 规则匹配的请求信息
+type filterRequest struct {
+	url      string
+	lowerURL string
+	host     string
+	pageHost string
+	typ      string // 浏览器资源类型，为空时不检查规则的类型限制
+	tokens   []string
+}
+
+// newFilterRequest 构造匹配请求
+func newFilterRequest(reqURL, pageURL, typ string) *filterRequest {
+	req := &filterRequest{url: reqURL, lowerURL: strings.ToLower(reqURL), typ: typ}
+	if u, err := url.Parse(reqURL); err == nil {
+		req.host = strings.ToLower(u.Hostname())
+	}
+	if u, err := url.Parse(pageURL); err == nil {
+		req.pageHost = strings.ToLower(u.Hostname())
+	}
+	return req
+}
+
+// urlTokens 请求地址中的字面词，去重后用于查找规则索引
+func (r *filterRequest) urlTokens() []string {
+	if r.tokens == nil {
+		seen := make(map[string]bool)
+		r.tokens = []string{}
+		for _, tok := range splitTokens(r.lowerURL) {
+			if !seen[tok] {
+				seen[tok] = true
+				r.tokens = append(r.tokens, tok)
+			}
+		}
+	}
+	return r.tokens
+}
+
+// thirdParty 请求是否来自第三方域名，按主域名的最后两级近似判断
+func (r *filterRequest) thirdParty() bool {
+	return baseDomain(r.host) != baseDomain(r.pageHost)
+}
+
+// baseDomain 取域名的最后两级
+func baseDomain(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) <= 2 {
+		return host
+	}
+	return strings.Join(parts[len(parts)-2:], ".")
+}
+
+// filterRule 单条网络过滤规则
+type filterRule struct {
+	raw        string
+	order      int // 规则在列表中的序号，多条规则命中时返回最先添加的规则
+	re         *regexp.Regexp
+	substr     string          // 不含通配符和锚点的规则按小写子串匹配
+	types      map[string]bool // 限定的资源类型，为空表示不限
+	notTypes   map[string]bool
+	thirdParty int // 1 仅第三方，-1 仅第一方，0 不限
+	domains    []string
+	notDomains []string
+}
+
+// match 规则是否匹配请求
+func (r *filterRule) match(req *filterRequest) bool {
+	if req.typ != "" {
+		if len(r.types) > 0 && !r.types[req.typ] {
+			return false
+		}
+		if r.notTypes[req.typ] {
+			return false
+		}
+	}
+	if r.thirdParty != 0 && req.pageHost != "" && (r.thirdParty > 0) != req.thirdParty() {
+		return false
+	}
+	if len(r.domains) > 0 && !hostMatches(req.pageHost, r.domains) {
+		return false
+	}
+	if len(r.notDomains) > 0 && hostMatches(req.pageHost, r.notDomains) {
+		return false
+	}
+
+	if r.re != nil {
+		return r.re.MatchString(req.url)
+	}
+	return strings.Contains(req.lowerURL, r.substr)
+}
+
+// hostMatches 域名或其上级域名是否在列表中
+func hostMatches(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterList AdBlock/EasyList 格式的网络过滤规则，支持 ||、|、^、* 语法，
+// @@ 例外规则以及 $third-party、$domain= 和资源类型选项。元素隐藏规则会被忽略。
+type FilterList struct {
+	blockHosts map[string]string // 纯域名规则 ||example.com^，值为原始规则
+	allowHosts map[string]string
+	block      ruleIndex
+	allow      ruleIndex
+	rules      int
+	skipped    int
+}
+
+// NewFilterList 从规则文本创建过滤列表，不支持的规则会被跳过
+func NewFilterList(rules []string) *FilterList {
+	f := &FilterList{
+		blockHosts: make(map[string]string),
+		allowHosts: make(map[string]string),
+	}
+	for _, line := range rules {
+		f.add(line)
+	}
+	return f
+}
+
+// LoadFilterLists 从本地文件加载过滤列表，多个文件合并为一个列表
+func LoadFilterLists(paths ...string) (*FilterList, error) {
+	f := NewFilterList(nil)
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("读取过滤列表失败: %v", err)
+		}
+		err = f.read(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取过滤列表 %s 失败: %v", p, err)
+		}
+	}
+	return f, nil
+}
+
+// read 逐行读取规则
+func (f *FilterList) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		f.add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Rules 返回已加载的规则数
+func (f *FilterList) Rules() int {
+	if f == nil {
+		return 0
+	}
+	return f.rules
+}
+
+// add 解析并添加一条规则
+func (f *FilterList) add(line string) {
+	line = strings.TrimSpace(line)
+	// 注释、列表头和元素隐藏规则
+	if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") ||
+		strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#?#") ||
+		strings.Contains(line, "#$#") {
+		return
+	}
+
+	exception := strings.HasPrefix(line, "@@")
+	pattern := strings.TrimPrefix(line, "@@")
+
+	// 纯域名规则走哈希表，EasyList 中占多数
+	if host, ok := plainHostRule(pattern); ok {
+		if exception {
+			f.allowHosts[host] = line
+		} else {
+			f.blockHosts[host] = line
+		}
+		f.rules++
+		return
+	}
+
+	rule, err := parseFilterRule(line, pattern)
+	if err != nil {
+		f.skipped++
+		return
+	}
+	rule.order = f.rules
+	if exception {
+		f.allow.add(rule, pattern)
+	} else {
+		f.block.add(rule, pattern)
+	}
+	f.rules++
+}
+
+// plainHostRule 判断是否为不带选项的 ||example.com^ 规则
+func plainHostRule(pattern string) (string, bool) {
+	if !strings.HasPrefix(pattern, "||") || !strings.HasSuffix(pattern, "^") {
+		return "", false
+	}
+	host := strings.ToLower(pattern[2 : len(pattern)-1])
+	if host == "" || strings.ContainsAny(host, "*^|/$:") {
+		return "", false
+	}
+	return host, true
+}
+
+// parseFilterRule 解析带通配符或选项的规则
+func parseFilterRule(raw, pattern string) (*filterRule, error) {
+	rule := &filterRule{raw: raw}
+	matchCase := false
+
+	// 选项
+	if idx := strings.LastIndex(pattern, "$"); idx >= 0 && !strings.HasSuffix(pattern, "/") {
+		for _, opt := range strings.Split(pattern[idx+1:], ",") {
+			opt = strings.ToLower(strings.TrimSpace(opt))
+			negate := strings.HasPrefix(opt, "~")
+			name := strings.TrimPrefix(opt, "~")
+			switch {
+			case name == "third-party" || name == "3p":
+				rule.thirdParty = 1
+				if negate {
+					rule.thirdParty = -1
+				}
+			case name == "first-party" || name == "1p":
+				rule.thirdParty = -1
+				if negate {
+					rule.thirdParty = 1
+				}
+			case strings.HasPrefix(opt, "domain="):
+				for _, d := range strings.Split(strings.TrimPrefix(opt, "domain="), "|") {
+					if strings.HasPrefix(d, "~") {
+						rule.notDomains = append(rule.notDomains, d[1:])
+					} else if d != "" {
+						rule.domains = append(rule.domains, d)
+					}
+				}
+			case name == "match-case":
+				matchCase = true
+			case name == "important":
+			case filterTypes[name] != nil:
+				target := &rule.types
+				if negate {
+					target = &rule.notTypes
+				}
+				if *target == nil {
+					*target = make(map[string]bool)
+				}
+				for _, t := range filterTypes[name] {
+					(*target)[t] = true
+				}
+			default:
+				return nil, fmt.Errorf("不支持的选项 %s", opt)
+			}
+		}
+		pattern = pattern[:idx]
+	}
+	if pattern == "" || pattern == "*" {
+		return nil, fmt.Errorf("规则过于宽泛")
+	}
+
+	// /.../ 形式的正则规则
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr := pattern[1 : len(pattern)-1]
+		if !matchCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		rule.re = re
+		return rule, nil
+	}
+
+	if !strings.ContainsAny(pattern, "*^|") && !matchCase {
+		rule.substr = strings.ToLower(pattern)
+		return rule, nil
+	}
+
+	re, err := regexp.Compile(filterPatternRegex(pattern, matchCase))
+	if err != nil {
+		return nil, err
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// filterPatternRegex 将 AdBlock 匹配模式转换为正则表达式
+func filterPatternRegex(pattern string, matchCase bool) string {
+	var b strings.Builder
+	if !matchCase {
+		b.WriteString("(?i)")
+	}
+	switch {
+	case strings.HasPrefix(pattern, "||"):
+		b.WriteString(`^[a-z][a-z0-9+.-]*://([^/?#]*\.)?`)
+		pattern = pattern[2:]
+	case strings.HasPrefix(pattern, "|"):
+		b.WriteString("^")
+		pattern = pattern[1:]
+	}
+	end := strings.HasSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "|")
+
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '^':
+			b.WriteString(`(?:[^\w.%-]|$)`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if end {
+		b.WriteString("$")
+	}
+	return b.String()
+}
+
+// blockRule 返回匹配请求的拦截规则
+func (f *FilterList) blockRule(req *filterRequest) string {
+	if f == nil {
+		return ""
+	}
+	if raw := lookupHost(f.blockHosts, req.host); raw != "" {
+		return raw
+	}
+	if r := f.block.match(req); r != nil {
+		return r.raw
+	}
+	return ""
+}
+
+// allowed 请求是否命中例外规则
+func (f *FilterList) allowed(req *filterRequest) bool {
+	if f == nil {
+		return false
+	}
+	if lookupHost(f.allowHosts, req.host) != "" {
+		return true
+	}
+	return f.allow.match(req) != nil
+}
+
+// ruleIndex 按规则中的字面词索引规则，匹配时只检查请求地址中出现的词对应的规则，
+// 与 blockHosts 一样避免逐条扫描。提取不到可靠字面词的规则 (如正则规则) 逐条检查
+type ruleIndex struct {
+	byToken map[string][]*filterRule
+	other   []*filterRule
+}
+
+// commonTokens 几乎每个地址都包含的词，只有规则中没有其他可靠字面词时才用于索引
+var commonTokens = map[string]bool{"http": true, "https": true, "www": true, "com": true}
+
+// add 添加规则，pattern 为去掉 @@ 前缀的规则文本
+func (ix *ruleIndex) add(r *filterRule, pattern string) {
+	tok := ""
+	if r.re == nil || !isRegexPattern(pattern) {
+		tok = ruleToken(pattern)
+	}
+	if tok == "" {
+		ix.other = append(ix.other, r)
+		return
+	}
+	if ix.byToken == nil {
+		ix.byToken = make(map[string][]*filterRule)
+	}
+	ix.byToken[tok] = append(ix.byToken[tok], r)
+}
+
+// match 返回匹配请求的最先添加的规则，没有时返回 nil
+func (ix *ruleIndex) match(req *filterRequest) *filterRule {
+	var best *filterRule
+	// 同一列表中的规则按添加顺序排列，序号大于已命中规则后无需继续
+	check := func(rules []*filterRule) {
+		for _, r := range rules {
+			if best != nil && r.order > best.order {
+				return
+			}
+			if r.match(req) {
+				best = r
+				return
+			}
+		}
+	}
+	if len(ix.byToken) > 0 {
+		for _, tok := range req.urlTokens() {
+			check(ix.byToken[tok])
+		}
+	}
+	check(ix.other)
+	return best
+}
+
+// isRegexPattern 是否为 /.../ 形式的正则规则
+func isRegexPattern(pattern string) bool {
+	if idx := strings.LastIndex(pattern, "$"); idx >= 0 && !strings.HasSuffix(pattern, "/") {
+		pattern = pattern[:idx]
+	}
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// ruleToken 提取规则中最长的可靠字面词。词两侧必须是分隔符或锚点，
+// 紧邻通配符或未锚定的规则边界的词可能只是地址中某个词的一部分，不能用于索引
+func ruleToken(pattern string) string {
+	if idx := strings.LastIndex(pattern, "$"); idx >= 0 {
+		pattern = pattern[:idx]
+	}
+	leftAnchored := strings.HasPrefix(pattern, "|")
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "|"), "|")
+	rightAnchored := strings.HasSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "|")
+
+	best, common := "", ""
+	for i := 0; i < len(pattern); {
+		if !isTokenChar(pattern[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(pattern) && isTokenChar(pattern[j]) {
+			j++
+		}
+		leftOK := (i == 0 && leftAnchored) || (i > 0 && pattern[i-1] != '*')
+		rightOK := (j == len(pattern) && rightAnchored) || (j < len(pattern) && pattern[j] != '*')
+		if tok := strings.ToLower(pattern[i:j]); leftOK && rightOK {
+			switch {
+			case commonTokens[tok]:
+				common = tok
+			case len(tok) > len(best):
+				best = tok
+			}
+		}
+		i = j
+	}
+	if best == "" {
+		return common
+	}
+	return best
+}
+
+// splitTokens 按非字母数字字符切分文本
+func splitTokens(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		if !isTokenChar(s[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isTokenChar(s[j]) {
+			j++
+		}
+		tokens = append(tokens, s[i:j])
+		i = j
+	}
+	return tokens
+}
+
+// isTokenChar 字面词由 ASCII 字母和数字组成
+func isTokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// lookupHost 依次查找域名及其上级域名
+func lookupHost(hosts map[string]string, host string) string {
+	for host != "" {
+		if raw, ok := hosts[host]; ok {
+			return raw
+		}
+		idx := strings.Index(host, ".")
+		if idx < 0 {
+			break
+		}
+		host = host[idx+1:]
+	}
+	return ""
+}
+
+// matchFilters 在全局列表和请求附加规则中匹配，返回命中的拦截规则，未拦截时返回空字符串
+func matchFilters(req *filterRequest, lists ...*FilterList) string {
+	rule := ""
+	for _, f := range lists {
+		if rule = f.blockRule(req); rule != "" {
+			break
+		}
+	}
+	if rule == "" {
+		return ""
+	}
+	for _, f := range lists {
+		if f.allowed(req) {
+			return ""
+		}
+	}
+	return rule
+}
@@ -0,0 +1,55 @@
+package sniffer
+
+import "testing"
+
+func TestFilterTypeRestriction(t *testing.T) {
+	f := NewFilterList([]string{
+		"||cdn.example.com^$script",
+		"/ads/*$image",
+		"||tracker.example.net^$media",
+	})
+	page := "https://www.example.org/play"
+
+	tests := []struct {
+		url     string
+		typ     string
+		blocked bool
+	}{
+		// 限定了其他资源类型的规则不影响媒体地址
+		{"https://cdn.example.com/v.m3u8", "Media", false},
+		{"https://x.com/ads/v.mp4", "Media", false},
+		// 同一地址作为规则限定的类型请求时仍被拦截
+		{"https://cdn.example.com/app.js", "Script", true},
+		{"https://x.com/ads/banner.png", "Image", true},
+		{"https://tracker.example.net/v.mp4", "Media", true},
+		{"https://tracker.example.net/t.js", "Script", false},
+	}
+	for _, tt := range tests {
+		got := matchFilters(newFilterRequest(tt.url, page, tt.typ), f) != ""
+		if got != tt.blocked {
+			t.Errorf("%s (%s): 拦截 = %v，应为 %v", tt.url, tt.typ, got, tt.blocked)
+		}
+	}
+}
+
+func TestFilterCandidatesKeepMedia(t *testing.T) {
+	f := NewFilterList([]string{"||cdn.example.com^$script", "/ads/*$image", "||ads.example.net^"})
+	page := "https://www.example.org/play"
+
+	// 与嗅探时的候选地址过滤一致，按媒体资源匹配
+	c := newCollector(func(u string) bool {
+		return matchFilters(newFilterRequest(u, page, "Media"), f) != ""
+	})
+	for _, u := range []string{
+		"https://cdn.example.com/v.m3u8",
+		"https://x.com/ads/v.mp4",
+		"https://ads.example.net/pre.mp4",
+	} {
+		c.add(URLWithHeaders{URL: u})
+	}
+
+	urls := c.close()
+	if len(urls) != 2 || urls[0].URL != "https://cdn.example.com/v.m3u8" || urls[1].URL != "https://x.com/ads/v.mp4" {
+		t.Fatalf("应只丢弃无类型限制的规则命中的地址，实际收集: %v", urls)
+	}
+}
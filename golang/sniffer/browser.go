@@ -0,0 +1,321 @@
+package sniffer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// BrowserStats 浏览器进程状态
+type BrowserStats struct {
+	ID          int    `json:"id"`
+	PID         int    `json:"pid"`
+	Healthy     bool   `json:"healthy"`
+	Pages       int    `json:"pages"`                // 当前打开的页面数
+	Restarts    int    `json:"restarts"`             // 累计重启次数
+	LastCrash   string `json:"last_crash,omitempty"` // 最近一次崩溃原因
+	LastCrashAt string `json:"last_crash_at,omitempty"`
+}
+
+// errBrowserDown 实例未运行
+var errBrowserDown = errors.New("浏览器进程未运行")
+
+// browserInstance 单个浏览器进程
+type browserInstance struct {
+	id          int
+	userDataDir string   // 非空时使用持久的用户数据目录，见 session
+	proxy       *url.URL // 非空时以该代理启动浏览器进程
+	mu          sync.Mutex
+	launcher    *launcher.Launcher
+	browser     *rod.Browser
+	generation  int // 每次重启加一，用于识别属于旧进程的页面
+	healthy     bool
+	relaunching bool
+	pages       int
+	restarts    int
+	lastCrash   string
+	lastCrashAt time.Time
+}
+
+// launchBrowser 启动浏览器进程并建立连接
+func (s *Sniffer) launchBrowser(l *launcher.Launcher) (*rod.Browser, error) {
+	controlURL, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("启动浏览器失败: %v", err)
+	}
+
+	browser := rod.New().ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		return nil, fmt.Errorf("连接浏览器失败: %v", err)
+	}
+	return browser, nil
+}
+
+// start 启动浏览器实例
+func (b *browserInstance) start(s *Sniffer) error {
+	l := s.newLauncher()
+	if b.userDataDir != "" {
+		l = l.UserDataDir(b.userDataDir)
+	}
+	if b.proxy != nil {
+		l = l.Proxy(proxyServer(b.proxy))
+	}
+	browser, err := s.launchBrowser(l)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.launcher = l
+	b.browser = browser
+	b.generation++
+	b.healthy = true
+	b.pages = 0
+	b.mu.Unlock()
+	return nil
+}
+
+// current 返回当前浏览器连接及其代数，不健康时返回 nil
+func (b *browserInstance) current() (*rod.Browser, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.healthy {
+		return nil, b.generation
+	}
+	return b.browser, b.generation
+}
+
+// alive 判断指定代数的页面是否仍属于存活的浏览器进程
+func (b *browserInstance) alive(generation int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy && b.generation == generation
+}
+
+// load 当前打开的页面数，不健康的实例返回 -1
+func (b *browserInstance) load() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.healthy {
+		return -1
+	}
+	return b.pages
+}
+
+// pageOpened 记录打开页面
+func (b *browserInstance) pageOpened(generation int) {
+	b.mu.Lock()
+	if b.generation == generation {
+		b.pages++
+	}
+	b.mu.Unlock()
+}
+
+// pageClosed 记录关闭页面
+func (b *browserInstance) pageClosed(generation int) {
+	b.mu.Lock()
+	if b.generation == generation && b.pages > 0 {
+		b.pages--
+	}
+	b.mu.Unlock()
+}
+
+// probe 探测浏览器进程是否仍可响应
+func (b *browserInstance) probe(timeout time.Duration) error {
+	browser, _ := b.current()
+	if browser == nil {
+		return errBrowserDown
+	}
+	browser = browser.Timeout(timeout)
+	defer browser.CancelTimeout()
+	_, err := proto.BrowserGetVersion{}.Call(browser)
+	return err
+}
+
+// markCrashed 标记实例崩溃，返回是否需要由调用方负责重启
+func (b *browserInstance) markCrashed(reason error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.relaunching {
+		return false
+	}
+	// 上次重启失败的实例保留最初的崩溃原因
+	if b.healthy {
+		b.healthy = false
+		b.lastCrash = reason.Error()
+		b.lastCrashAt = time.Now()
+	}
+	b.relaunching = true
+	return true
+}
+
+// crashed 标记指定代数的进程崩溃并结束进程，不自动重启，用于按需启动的会话浏览器。
+// 进程已关闭、已重启或正在重启时返回 false
+func (b *browserInstance) crashed(generation int, reason error) bool {
+	b.mu.Lock()
+	if !b.healthy || b.relaunching || b.generation != generation {
+		b.mu.Unlock()
+		return false
+	}
+	b.healthy = false
+	b.lastCrash = reason.Error()
+	b.lastCrashAt = time.Now()
+	l := b.launcher
+	b.mu.Unlock()
+
+	if l != nil {
+		l.Kill()
+	}
+	return true
+}
+
+// relaunch 杀掉旧进程并重新启动，失败时保持不健康状态等待下一轮探测
+func (b *browserInstance) relaunch(s *Sniffer) {
+	b.mu.Lock()
+	old := b.launcher
+	b.mu.Unlock()
+
+	if old != nil {
+		old.Kill()
+		// 持久用户数据目录不能清理
+		if b.userDataDir == "" {
+			go old.Cleanup()
+		}
+	}
+
+	if s.closed() {
+		b.mu.Lock()
+		b.relaunching = false
+		b.mu.Unlock()
+		return
+	}
+	err := b.start(s)
+
+	b.mu.Lock()
+	b.relaunching = false
+	if err == nil {
+		b.restarts++
+	}
+	b.mu.Unlock()
+
+	// 启动期间嗅探器已关闭时，关闭刚启动的进程
+	if err == nil && s.closed() {
+		b.close()
+		return
+	}
+
+	if err != nil {
+		s.log("浏览器实例", b.id, "重启失败:", err)
+		return
+	}
+	s.log("浏览器实例", b.id, "已重启")
+}
+
+// stats 返回实例状态
+func (b *browserInstance) stats() BrowserStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BrowserStats{
+		ID:        b.id,
+		Healthy:   b.healthy,
+		Pages:     b.pages,
+		Restarts:  b.restarts,
+		LastCrash: b.lastCrash,
+	}
+	if b.launcher != nil {
+		st.PID = b.launcher.PID()
+	}
+	if !b.lastCrashAt.IsZero() {
+		st.LastCrashAt = b.lastCrashAt.Format(time.RFC3339)
+	}
+	return st
+}
+
+// close 关闭浏览器实例
+func (b *browserInstance) close() error {
+	b.mu.Lock()
+	browser, l := b.browser, b.launcher
+	b.healthy = false
+	b.mu.Unlock()
+
+	if browser == nil {
+		return nil
+	}
+	err := browser.Close()
+	if err != nil && l != nil {
+		// 进程已无响应时直接结束
+		l.Kill()
+	}
+	return err
+}
+
+// pickBrowser 选择页面数最少的健康实例
+func (s *Sniffer) pickBrowser() (*browserInstance, error) {
+	var picked *browserInstance
+	best := -1
+	for _, b := range s.browsers {
+		load := b.load()
+		if load < 0 {
+			continue
+		}
+		if picked == nil || load < best {
+			picked, best = b, load
+		}
+	}
+	if picked == nil {
+		return nil, ErrBrowserUnavailable
+	}
+	return picked, nil
+}
+
+// checkBrowser 探测单个实例，无响应时标记崩溃并后台重启
+func (s *Sniffer) checkBrowser(b *browserInstance) {
+	// 关闭后不再重启，避免遗留浏览器进程
+	if s.closed() {
+		return
+	}
+	timeout := time.Duration(s.config.HeadTimeout) * time.Millisecond
+	err := b.probe(timeout)
+	if err == nil {
+		return
+	}
+	if b.markCrashed(err) {
+		s.log("浏览器实例", b.id, "不可用，准备重启:", err)
+		go b.relaunch(s)
+	}
+}
+
+// healthLoop 定期探测所有浏览器实例和运行中的会话浏览器，直到嗅探器关闭
+func (s *Sniffer) healthLoop() {
+	ticker := time.NewTicker(time.Duration(s.config.HealthInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			for _, b := range s.browsers {
+				s.checkBrowser(b)
+			}
+			s.checkSessions()
+			s.reapSessions()
+		}
+	}
+}
+
+// BrowserStats 返回所有浏览器实例的状态
+func (s *Sniffer) BrowserStats() []BrowserStats {
+	stats := make([]BrowserStats, 0, len(s.browsers))
+	for _, b := range s.browsers {
+		stats = append(stats, b.stats())
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package sniffer
+
+import (
+	"sync"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// collector 并发安全的候选地址收集器，由请求拦截器和 HEAD 探测协程共同写入。
+// 地址按发现顺序去重保存，close 之后到达的地址会被丢弃，保证返回的结果不再变化。
+type collector struct {
+	mu     sync.Mutex
+	urls   []URLWithHeaders
+	seen   map[string]bool
+	probed map[string]bool
+	closed bool
+	drop   func(url string) bool  // 返回 true 的地址不会被收集，如命中广告过滤规则
+	onAdd  func(u URLWithHeaders) // 收集到新地址时调用，不持有锁，可以为 nil
+}
+
+// newCollector 创建收集器，drop 可以为 nil
+func newCollector(drop func(url string) bool) *collector {
+	return &collector{
+		seen:   make(map[string]bool),
+		probed: make(map[string]bool),
+		drop:   drop,
+	}
+}
+
+// add 添加候选地址，地址已存在、被丢弃或收集器已关闭时返回 false
+func (c *collector) add(u URLWithHeaders) bool {
+	if !c.append(u) {
+		return false
+	}
+	if c.onAdd != nil {
+		c.onAdd(u)
+	}
+	return true
+}
+
+// append 在锁内去重并保存地址
+func (c *collector) append(u URLWithHeaders) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.seen[u.URL] {
+		return false
+	}
+	c.seen[u.URL] = true
+	if c.drop != nil && c.drop(u.URL) {
+		return false
+	}
+	c.urls = append(c.urls, u)
+	return true
+}
+
+// tryProbe 标记地址需要 HEAD 探测，同一地址只返回一次 true
+func (c *collector) tryProbe(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.probed[url] {
+		return false
+	}
+	c.probed[url] = true
+	return true
+}
+
+// close 停止收集并返回按发现顺序排列的地址
+func (c *collector) close() []URLWithHeaders {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	urls := make([]URLWithHeaders, len(c.urls))
+	copy(urls, c.urls)
+	return urls
+}
+
+// candidateHeaders 提取播放地址需要携带的请求头
+func candidateHeaders(headers proto.NetworkHeaders) map[string]string {
+	reqHeaders := make(map[string]string)
+	if referer, ok := headers["referer"]; ok && referer.String() != "" {
+		reqHeaders["referer"] = referer.String()
+	}
+	if userAgent, ok := headers["user-agent"]; ok && userAgent.String() != "" {
+		reqHeaders["user-agent"] = userAgent.String()
+	}
+	return reqHeaders
+}
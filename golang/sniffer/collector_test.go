@@ -0,0 +1,226 @@
+package sniffer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 以下测试需要使用 go test -race 运行，验证收集器在并发写入时没有数据竞争
+
+func TestCollectorDedup(t *testing.T) {
+	c := newCollector(nil)
+	var added atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/%d.m3u8", i)}) {
+					added.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	urls := c.close()
+	if len(urls) != 100 || added.Load() != 100 {
+		t.Fatalf("去重后应有 100 个地址，实际收集 %d 个，add 返回 true %d 次", len(urls), added.Load())
+	}
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if seen[u.URL] {
+			t.Fatalf("地址重复: %s", u.URL)
+		}
+		seen[u.URL] = true
+	}
+}
+
+func TestCollectorOrder(t *testing.T) {
+	c := newCollector(nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/%d/%d.mp4", g, i)})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// 每个协程内的地址保持发现顺序
+	last := make(map[int]int)
+	for _, u := range c.close() {
+		var g, i int
+		if _, err := fmt.Sscanf(u.URL, "https://a.com/%d/%d.mp4", &g, &i); err != nil {
+			t.Fatalf("无法解析地址 %s: %v", u.URL, err)
+		}
+		if prev, ok := last[g]; ok && i <= prev {
+			t.Fatalf("协程 %d 的地址顺序错误: %d 出现在 %d 之后", g, i, prev)
+		}
+		last[g] = i
+	}
+	if len(last) != 16 {
+		t.Fatalf("应收集到 16 个协程的地址，实际 %d 个", len(last))
+	}
+
+	// 单个协程按添加顺序返回
+	c = newCollector(nil)
+	want := []string{"https://b.com/3.m3u8", "https://b.com/1.m3u8", "https://b.com/2.m3u8"}
+	for _, u := range want {
+		c.add(URLWithHeaders{URL: u})
+	}
+	c.add(URLWithHeaders{URL: want[0]})
+	got := c.close()
+	if len(got) != len(want) {
+		t.Fatalf("应有 %d 个地址，实际 %d 个", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].URL != want[i] {
+			t.Fatalf("第 %d 个地址应为 %s，实际为 %s", i, want[i], got[i].URL)
+		}
+	}
+}
+
+func TestCollectorClose(t *testing.T) {
+	c := newCollector(nil)
+	var wg sync.WaitGroup
+	var closed []URLWithHeaders
+	start := make(chan struct{})
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < 100; i++ {
+				u := fmt.Sprintf("https://a.com/%d/%d.m3u8", g, i)
+				c.add(URLWithHeaders{URL: u})
+				c.tryProbe(u)
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-start
+		closed = c.close()
+	}()
+	close(start)
+	wg.Wait()
+
+	// close 返回的结果不受之后的写入影响
+	after := c.close()
+	if len(after) != len(closed) {
+		t.Fatalf("close 之后仍收集到地址: %d -> %d", len(closed), len(after))
+	}
+	if c.add(URLWithHeaders{URL: "https://a.com/late.m3u8"}) {
+		t.Fatal("close 之后 add 应返回 false")
+	}
+	if c.tryProbe("https://a.com/late.m3u8") {
+		t.Fatal("close 之后 tryProbe 应返回 false")
+	}
+	if got := c.close(); len(got) != len(closed) {
+		t.Fatalf("close 之后的地址被收集: %d -> %d", len(closed), len(got))
+	}
+}
+
+func TestCollectorTryProbe(t *testing.T) {
+	c := newCollector(nil)
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if c.tryProbe(fmt.Sprintf("https://a.com/%d", i)) {
+					granted.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if granted.Load() != 20 {
+		t.Fatalf("每个地址只应探测一次，实际 %d 次", granted.Load())
+	}
+}
+
+func TestCollectorDrop(t *testing.T) {
+	var calls atomic.Int32
+	c := newCollector(func(url string) bool {
+		calls.Add(1)
+		return strings.Contains(url, "/ad/")
+	})
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/ad/%d.m3u8", i)})
+				c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/v/%d.m3u8", i)})
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, u := range c.close() {
+		if strings.Contains(u.URL, "/ad/") {
+			t.Fatalf("被丢弃的地址仍被收集: %s", u.URL)
+		}
+	}
+	// 同一地址只判断一次，被丢弃的地址再次出现时不重复调用 drop
+	if calls.Load() != 20 {
+		t.Fatalf("drop 应调用 20 次，实际 %d 次", calls.Load())
+	}
+	if c.add(URLWithHeaders{URL: "https://a.com/ad/0.m3u8"}) {
+		t.Fatal("被丢弃的地址 add 应返回 false")
+	}
+}
+
+func TestCollectorOnAdd(t *testing.T) {
+	c := newCollector(func(url string) bool { return strings.HasSuffix(url, ".ts") })
+	var mu sync.Mutex
+	notified := make(map[string]int)
+	c.onAdd = func(u URLWithHeaders) {
+		// onAdd 在锁外调用，回调中可以访问收集器
+		c.tryProbe(u.URL)
+		mu.Lock()
+		notified[u.URL]++
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/%d.m3u8", i)})
+				c.add(URLWithHeaders{URL: fmt.Sprintf("https://a.com/%d.ts", i)})
+			}
+		}()
+	}
+	wg.Wait()
+
+	urls := c.close()
+	if len(notified) != len(urls) || len(urls) != 10 {
+		t.Fatalf("应通知 10 个地址，实际通知 %d 个，收集 %d 个", len(notified), len(urls))
+	}
+	for _, u := range urls {
+		if notified[u.URL] != 1 {
+			t.Fatalf("地址 %s 通知了 %d 次", u.URL, notified[u.URL])
+		}
+	}
+
+	c.add(URLWithHeaders{URL: "https://a.com/late.m3u8"})
+	if _, ok := notified["https://a.com/late.m3u8"]; ok {
+		t.Fatal("close 之后的地址不应通知")
+	}
+}
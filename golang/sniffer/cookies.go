@@ -0,0 +1,128 @@
+package sniffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Cookie 注入或捕获的 Cookie
+type Cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain,omitempty"`
+	Path     string  `json:"path,omitempty"`
+	Expires  float64 `json:"expires,omitempty"` // Unix 时间戳（秒），为 0 表示会话 Cookie
+	HTTPOnly bool    `json:"http_only,omitempty"`
+	Secure   bool    `json:"secure,omitempty"`
+	SameSite string  `json:"same_site,omitempty"`
+}
+
+// ParseCookies 解析 cookies 参数，支持原始 Cookie 请求头字符串（如 "a=1; b=2"）
+// 或 JSON 数组（如 [{"name":"a","value":"1","domain":".example.com","path":"/"}]）
+func ParseCookies(raw string) ([]Cookie, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(raw, "[") {
+		var cookies []Cookie
+		if err := json.Unmarshal([]byte(raw), &cookies); err != nil {
+			return nil, fmt.Errorf("%w: cookies JSON 格式错误: %v", ErrInvalidOption, err)
+		}
+		for _, c := range cookies {
+			if c.Name == "" {
+				return nil, fmt.Errorf("%w: cookie 缺少 name", ErrInvalidOption)
+			}
+		}
+		return cookies, nil
+	}
+
+	var cookies []Cookie
+	for _, pair := range strings.Split(raw, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: cookie 格式错误: %s", ErrInvalidOption, pair)
+		}
+		cookies = append(cookies, Cookie{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return cookies, nil
+}
+
+// setCookies 在导航前注入 Cookie，未指定 domain 的 Cookie 作用于目标页面 URL
+func setCookies(page *rod.Page, cookies []Cookie, pageURL string) error {
+	if len(cookies) == 0 {
+		return nil
+	}
+	return proto.NetworkSetCookies{Cookies: toCookieParams(cookies, pageURL)}.Call(page)
+}
+
+// toCookieParams 转换为 CDP Cookie 参数，未指定 domain 的 Cookie 使用 pageURL
+func toCookieParams(cookies []Cookie, pageURL string) []*proto.NetworkCookieParam {
+	params := make([]*proto.NetworkCookieParam, 0, len(cookies))
+	for _, c := range cookies {
+		param := &proto.NetworkCookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: proto.NetworkCookieSameSite(c.SameSite),
+			Expires:  proto.TimeSinceEpoch(c.Expires),
+		}
+		if c.Domain == "" {
+			param.URL = pageURL
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// captureCookies 获取页面结束时目标 URL 和当前 URL 可用的 Cookie，供调用方播放时复用
+func captureCookies(page *rod.Page, pageURL string) ([]Cookie, error) {
+	page = page.Timeout(2 * time.Second)
+	defer page.CancelTimeout()
+
+	urls := []string{pageURL}
+	if info, err := page.Info(); err == nil && info.URL != pageURL && strings.HasPrefix(info.URL, "http") {
+		urls = append(urls, info.URL)
+	}
+
+	res, err := proto.NetworkGetCookies{Urls: urls}.Call(page)
+	if err != nil {
+		return nil, err
+	}
+	return fromNetworkCookies(res.Cookies), nil
+}
+
+// fromNetworkCookies 转换 CDP 返回的 Cookie
+func fromNetworkCookies(list []*proto.NetworkCookie) []Cookie {
+	cookies := make([]Cookie, 0, len(list))
+	for _, c := range list {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HTTPOnly: c.HTTPOnly,
+			Secure:   c.Secure,
+			SameSite: string(c.SameSite),
+		}
+		if !c.Session {
+			cookie.Expires = float64(c.Expires)
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
+}
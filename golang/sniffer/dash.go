@@ -0,0 +1,285 @@
+package sniffer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DASHInfo DASH 清单解析结果
+type DASHInfo struct {
+	Valid     bool         `json:"valid"`
+	Error     string       `json:"error,omitempty"`    // 无效原因，如 HTTP 403、不是 DASH 清单
+	Type      string       `json:"type,omitempty"`     // static 点播，dynamic 直播
+	Duration  float64      `json:"duration,omitempty"` // 总时长（秒）
+	Periods   []DASHPeriod `json:"periods"`
+	Encrypted bool         `json:"encrypted"`     // 是否包含 ContentProtection
+	DRM       []string     `json:"drm,omitempty"` // 出现的 DRM 系统，如 Widevine、PlayReady
+}
+
+// DASHPeriod DASH 时段
+type DASHPeriod struct {
+	ID             string              `json:"id,omitempty"`
+	Start          float64             `json:"start,omitempty"`
+	Duration       float64             `json:"duration,omitempty"`
+	AdaptationSets []DASHAdaptationSet `json:"adaptation_sets"`
+}
+
+// DASHAdaptationSet DASH 自适应集，同一内容的不同码率
+type DASHAdaptationSet struct {
+	ID                string                  `json:"id,omitempty"`
+	ContentType       string                  `json:"content_type,omitempty"` // video、audio、text
+	MimeType          string                  `json:"mime_type,omitempty"`
+	Lang              string                  `json:"lang,omitempty"`
+	Representations   []DASHRepresentation    `json:"representations"`
+	ContentProtection []DASHContentProtection `json:"content_protection,omitempty"`
+}
+
+// DASHRepresentation DASH 表示，即一路具体码率的流
+type DASHRepresentation struct {
+	ID        string `json:"id,omitempty"`
+	Bandwidth int    `json:"bandwidth"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	FrameRate string `json:"frame_rate,omitempty"`
+	Codecs    string `json:"codecs,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+}
+
+// DASHContentProtection DRM 保护信息
+type DASHContentProtection struct {
+	SchemeIDURI string `json:"scheme_id_uri"`
+	Value       string `json:"value,omitempty"`
+	DefaultKID  string `json:"default_kid,omitempty"`
+	System      string `json:"system,omitempty"` // 按 scheme 识别出的 DRM 系统名称
+}
+
+// drmSystems 常见 DRM 系统 ID
+var drmSystems = map[string]string{
+	"edef8ba9-79d6-4ace-a3c8-27dcd51d21ed": "Widevine",
+	"9a04f079-9840-4286-ab92-e65be0885f95": "PlayReady",
+	"94ce86fb-07ff-4f43-adb8-93d2fa968ca2": "FairPlay",
+	"1077efec-c0b2-4d02-ace3-3c1e52e2fb4b": "ClearKey",
+	"e2719d58-a985-b3c9-781a-b030af78d30e": "ClearKey",
+	"3d5e6d35-9b9a-41e8-b843-dd3c6e72c42c": "ChinaDRM",
+}
+
+// mpd 清单 XML 结构，只解析需要的字段
+type mpd struct {
+	XMLName  xml.Name    `xml:"MPD"`
+	Type     string      `xml:"type,attr"`
+	Duration string      `xml:"mediaPresentationDuration,attr"`
+	Periods  []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	Duration       string             `xml:"duration,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	ID                string                 `xml:"id,attr"`
+	ContentType       string                 `xml:"contentType,attr"`
+	MimeType          string                 `xml:"mimeType,attr"`
+	Lang              string                 `xml:"lang,attr"`
+	Codecs            string                 `xml:"codecs,attr"`
+	ContentProtection []mpdContentProtection `xml:"ContentProtection"`
+	Representations   []mpdRepresentation    `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID                string                 `xml:"id,attr"`
+	Bandwidth         int                    `xml:"bandwidth,attr"`
+	Width             int                    `xml:"width,attr"`
+	Height            int                    `xml:"height,attr"`
+	FrameRate         string                 `xml:"frameRate,attr"`
+	Codecs            string                 `xml:"codecs,attr"`
+	MimeType          string                 `xml:"mimeType,attr"`
+	ContentProtection []mpdContentProtection `xml:"ContentProtection"`
+}
+
+type mpdContentProtection struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+	DefaultKID  string `xml:"default_KID,attr"`
+}
+
+// isDASH 候选地址是否为 DASH 清单
+func isDASH(c *URLWithHeaders) bool {
+	return strings.Contains(strings.ToLower(c.URL), ".mpd") ||
+		strings.Contains(strings.ToLower(c.ContentType), "dash+xml")
+}
+
+// parseDASHCandidates 并发解析候选地址中的 DASH 清单，结果写入各候选的 DASH 字段
+func parseDASHCandidates(ctx context.Context, client *http.Client, candidates []URLWithHeaders) {
+	var wg sync.WaitGroup
+	for i := range candidates {
+		if !isDASH(&candidates[i]) {
+			continue
+		}
+		wg.Add(1)
+		go func(c *URLWithHeaders) {
+			defer wg.Done()
+			body, _, err := fetchManifest(ctx, client, c.URL, c.Headers)
+			if err != nil {
+				c.DASH = &DASHInfo{Error: err.Error()}
+				return
+			}
+			info, err := parseMPD(body)
+			if err != nil {
+				c.DASH = &DASHInfo{Error: err.Error()}
+				return
+			}
+			c.DASH = info
+		}(&candidates[i])
+	}
+	wg.Wait()
+}
+
+// parseMPD 解析 DASH 清单
+func parseMPD(body []byte) (*DASHInfo, error) {
+	var m mpd
+	if err := xml.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("不是 DASH 清单: %v", err)
+	}
+	if len(m.Periods) == 0 {
+		return nil, fmt.Errorf("DASH 清单没有 Period")
+	}
+
+	info := &DASHInfo{
+		Valid:    true,
+		Type:     m.Type,
+		Duration: parseISODuration(m.Duration),
+		Periods:  make([]DASHPeriod, 0, len(m.Periods)),
+	}
+	if info.Type == "" {
+		info.Type = "static"
+	}
+
+	drm := make(map[string]bool)
+	for _, p := range m.Periods {
+		period := DASHPeriod{
+			ID:             p.ID,
+			Start:          parseISODuration(p.Start),
+			Duration:       parseISODuration(p.Duration),
+			AdaptationSets: make([]DASHAdaptationSet, 0, len(p.AdaptationSets)),
+		}
+		for _, as := range p.AdaptationSets {
+			set := DASHAdaptationSet{
+				ID:              as.ID,
+				ContentType:     as.ContentType,
+				MimeType:        as.MimeType,
+				Lang:            as.Lang,
+				Representations: make([]DASHRepresentation, 0, len(as.Representations)),
+			}
+
+			// ContentProtection 可出现在自适应集或表示上，统一汇总到自适应集
+			seen := make(map[string]bool)
+			addProtection := func(list []mpdContentProtection) {
+				for _, cp := range list {
+					key := cp.SchemeIDURI + "|" + cp.Value + "|" + cp.DefaultKID
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					prot := DASHContentProtection{
+						SchemeIDURI: cp.SchemeIDURI,
+						Value:       cp.Value,
+						DefaultKID:  cp.DefaultKID,
+						System:      drmSystem(cp.SchemeIDURI),
+					}
+					if prot.System != "" {
+						drm[prot.System] = true
+					}
+					set.ContentProtection = append(set.ContentProtection, prot)
+				}
+			}
+			addProtection(as.ContentProtection)
+
+			for _, r := range as.Representations {
+				rep := DASHRepresentation{
+					ID:        r.ID,
+					Bandwidth: r.Bandwidth,
+					Width:     r.Width,
+					Height:    r.Height,
+					FrameRate: r.FrameRate,
+					Codecs:    r.Codecs,
+					MimeType:  r.MimeType,
+				}
+				// 表示未指定时继承自适应集的属性
+				if rep.Codecs == "" {
+					rep.Codecs = as.Codecs
+				}
+				if rep.MimeType == "" {
+					rep.MimeType = as.MimeType
+				}
+				set.Representations = append(set.Representations, rep)
+				addProtection(r.ContentProtection)
+			}
+
+			if set.ContentType == "" {
+				set.ContentType = contentTypeFromMime(set.MimeType)
+				if set.ContentType == "" && len(set.Representations) > 0 {
+					set.ContentType = contentTypeFromMime(set.Representations[0].MimeType)
+				}
+			}
+			if len(set.ContentProtection) > 0 {
+				info.Encrypted = true
+			}
+			period.AdaptationSets = append(period.AdaptationSets, set)
+		}
+		info.Periods = append(info.Periods, period)
+	}
+
+	for system := range drm {
+		info.DRM = append(info.DRM, system)
+	}
+	sort.Strings(info.DRM)
+	return info, nil
+}
+
+// drmSystem 根据 schemeIdUri 识别 DRM 系统，通用加密标记返回空字符串
+func drmSystem(scheme string) string {
+	return drmSystems[strings.TrimPrefix(strings.ToLower(scheme), "urn:uuid:")]
+}
+
+// contentTypeFromMime 从 MIME 类型推断内容类型
+func contentTypeFromMime(mime string) string {
+	if idx := strings.Index(mime, "/"); idx > 0 {
+		switch t := mime[:idx]; t {
+		case "video", "audio", "text":
+			return t
+		case "application":
+			return "text"
+		}
+	}
+	return ""
+}
+
+// isoDurationRegex ISO 8601 时长，如 PT1H2M3.5S、P1DT2H
+var isoDurationRegex = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration 解析 ISO 8601 时长为秒，格式错误时返回 0
+func parseISODuration(s string) float64 {
+	m := isoDurationRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	units := []float64{86400, 3600, 60, 1}
+	var total float64
+	for i, unit := range units {
+		if m[i+1] != "" {
+			v, _ := strconv.ParseFloat(m[i+1], 64)
+			total += v * unit
+		}
+	}
+	return total
+}
@@ -0,0 +1,193 @@
+package sniffer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Device 设备模拟参数，UA、视口、触屏和像素比保持一致
+type Device struct {
+	Name      string  `json:"name"`
+	Title     string  `json:"title"`
+	UserAgent string  `json:"user_agent"`
+	Platform  string  `json:"platform"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	DPR       float64 `json:"dpr"`
+	Mobile    bool    `json:"mobile"`
+	Touch     bool    `json:"touch"`
+}
+
+// 默认设备名称
+const (
+	DevicePC     = "pc"
+	DeviceMobile = "iphonex"
+)
+
+const (
+	uaChromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaChromeMac     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaIPhone        = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	uaIPad          = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+)
+
+// deviceCatalog 内置设备目录
+var deviceCatalog = map[string]Device{
+	// 桌面
+	"pc": {
+		Title: "Desktop 1920x1080", UserAgent: uaChromeWindows, Platform: "Win32",
+		Width: 1920, Height: 1080, DPR: 1,
+	},
+	"pc-720p": {
+		Title: "Desktop 1280x720", UserAgent: uaChromeWindows, Platform: "Win32",
+		Width: 1280, Height: 720, DPR: 1,
+	},
+	"pc-1440p": {
+		Title: "Desktop 2560x1440", UserAgent: uaChromeWindows, Platform: "Win32",
+		Width: 2560, Height: 1440, DPR: 1,
+	},
+	"pc-4k": {
+		Title: "Desktop 4K (1920x1080@2x)", UserAgent: uaChromeWindows, Platform: "Win32",
+		Width: 1920, Height: 1080, DPR: 2,
+	},
+	"macbook": {
+		Title: "MacBook Pro 14", UserAgent: uaChromeMac, Platform: "MacIntel",
+		Width: 1512, Height: 982, DPR: 2,
+	},
+
+	// iOS
+	"iphonex": {
+		Title: "iPhone X", UserAgent: uaIPhone, Platform: "iPhone",
+		Width: 375, Height: 812, DPR: 3, Mobile: true, Touch: true,
+	},
+	"iphone14pro": {
+		Title: "iPhone 14 Pro", UserAgent: uaIPhone, Platform: "iPhone",
+		Width: 393, Height: 852, DPR: 3, Mobile: true, Touch: true,
+	},
+	"ipad": {
+		Title: "iPad", UserAgent: uaIPad, Platform: "iPad",
+		Width: 810, Height: 1080, DPR: 2, Mobile: true, Touch: true,
+	},
+	"ipadpro": {
+		Title: "iPad Pro 12.9", UserAgent: uaIPad, Platform: "iPad",
+		Width: 1024, Height: 1366, DPR: 2, Mobile: true, Touch: true,
+	},
+
+	// Android
+	"pixel7": {
+		Title:     "Pixel 7",
+		UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		Platform:  "Linux armv8l",
+		Width:     412, Height: 915, DPR: 2.625, Mobile: true, Touch: true,
+	},
+	"galaxys23": {
+		Title:     "Galaxy S23",
+		UserAgent: "Mozilla/5.0 (Linux; Android 14; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		Platform:  "Linux armv8l",
+		Width:     360, Height: 780, DPR: 3, Mobile: true, Touch: true,
+	},
+	"xiaomi13": {
+		Title:     "Xiaomi 13",
+		UserAgent: "Mozilla/5.0 (Linux; Android 13; 2211133C) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		Platform:  "Linux armv8l",
+		Width:     393, Height: 851, DPR: 2.75, Mobile: true, Touch: true,
+	},
+	"android-tablet": {
+		Title:     "Galaxy Tab S8",
+		UserAgent: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		Platform:  "Linux armv8l",
+		Width:     800, Height: 1280, DPR: 2, Mobile: true, Touch: true,
+	},
+
+	// 电视
+	"tv-android": {
+		Title:     "Android TV",
+		UserAgent: "Mozilla/5.0 (Linux; Android 11; BRAVIA 4K UR3 Build/RTM1.210628.001) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		Platform:  "Linux armv7l",
+		Width:     1920, Height: 1080, DPR: 1,
+	},
+	"tv-tizen": {
+		Title:     "Samsung Tizen TV",
+		UserAgent: "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.5) AppleWebKit/537.36 (KHTML, like Gecko) 85.0.4183.93/6.5 TV Safari/537.36",
+		Platform:  "Linux armv7l",
+		Width:     1920, Height: 1080, DPR: 1,
+	},
+	"tv-webos": {
+		Title:     "LG webOS TV",
+		UserAgent: "Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.128 Safari/537.36 WebAppManager",
+		Platform:  "Linux armv7l",
+		Width:     1920, Height: 1080, DPR: 1,
+	},
+}
+
+// LookupDevice 按名称查找设备，名称不区分大小写
+func LookupDevice(name string) (Device, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	d, ok := deviceCatalog[name]
+	d.Name = name
+	return d, ok
+}
+
+// Devices 返回按名称排序的设备目录
+func Devices() []Device {
+	list := make([]Device, 0, len(deviceCatalog))
+	for name, d := range deviceCatalog {
+		d.Name = name
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// resolveDevice 确定本次请求使用的设备：显式指定的 device 优先，其次 is_pc，最后是全局 DeviceType
+func (s *Sniffer) resolveDevice(options *SnifferOptions) (Device, bool) {
+	if options.Device != "" {
+		return LookupDevice(options.Device)
+	}
+
+	// 请求未指定 is_pc 时才使用全局设备类型
+	pc := strings.Contains(s.config.DeviceType, "pc")
+	if options.IsPc != nil {
+		pc = *options.IsPc
+	}
+	name := DeviceMobile
+	if pc {
+		name = DevicePC
+	}
+	d, ok := LookupDevice(name)
+	// 全局 UserAgent 只覆盖默认设备，不影响显式指定的设备
+	if s.config.UserAgent != "" {
+		d.UserAgent = s.config.UserAgent
+	}
+	return d, ok
+}
+
+// emulateDevice 在页面上应用设备参数
+func emulateDevice(page *rod.Page, d Device) error {
+	err := proto.EmulationSetDeviceMetricsOverride{
+		Width:             d.Width,
+		Height:            d.Height,
+		DeviceScaleFactor: d.DPR,
+		Mobile:            d.Mobile,
+	}.Call(page)
+	if err != nil {
+		return err
+	}
+
+	maxTouchPoints := 5
+	touch := proto.EmulationSetTouchEmulationEnabled{Enabled: d.Touch}
+	if d.Touch {
+		touch.MaxTouchPoints = &maxTouchPoints
+	}
+	if err := touch.Call(page); err != nil {
+		return err
+	}
+
+	return proto.NetworkSetUserAgentOverride{
+		UserAgent: d.UserAgent,
+		Platform:  d.Platform,
+	}.Call(page)
+}
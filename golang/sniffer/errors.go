@@ -0,0 +1,50 @@
+package sniffer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 预定义错误，调用方可通过 errors.Is 判断失败原因
+var (
+	ErrBrowserNotReady    = errors.New("浏览器未初始化")
+	ErrBrowserUnavailable = errors.New("没有可用的浏览器实例")
+	ErrInvalidURL         = errors.New("无效的 URL")
+	ErrInvalidOption      = errors.New("无效的参数")
+	ErrPage               = errors.New("创建页面失败")
+	ErrQueueTimeout       = errors.New("等待空闲页面超时")
+	ErrNavigation         = errors.New("页面导航失败")
+	ErrContent            = errors.New("获取页面源码失败")
+	ErrNotFound           = errors.New("未嗅探到媒体地址")
+	ErrSessionNotFound    = errors.New("会话不存在")
+	ErrSessionBusy        = errors.New("会话正在使用")
+)
+
+// Error 嗅探过程中的错误，记录失败的阶段、目标 URL 以及错误类型
+type Error struct {
+	Op   string // 失败阶段，如 "sniffer"、"fetch"
+	URL  string // 目标页面 URL
+	Kind error  // 预定义错误类型，如 ErrNavigation
+	Err  error  // 底层错误，可能为空
+}
+
+// newError 创建嗅探错误
+func newError(op, url string, kind, err error) *Error {
+	return &Error{Op: op, URL: url, Kind: kind, Err: err}
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.URL, e.Kind)
+	}
+	return fmt.Sprintf("%s %s: %v: %v", e.Op, e.URL, e.Kind, e.Err)
+}
+
+// Unwrap 支持 errors.Is/As 同时匹配错误类型和底层错误
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
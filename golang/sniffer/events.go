@@ -0,0 +1,34 @@
+package sniffer
+
+import "time"
+
+// 嗅探过程事件类型，候选地址通过 SnifferOptions.OnCandidate 单独通知
+const (
+	EventNavigate = "navigate" // 页面导航结束，导航失败时带有 Error，嗅探会继续进行
+	EventCSS      = "css"      // CSS 选择器等待结束
+	EventActions  = "actions"  // 交互步骤执行结束
+	EventScript   = "script"   // 页面脚本已注入
+)
+
+// Event 嗅探过程事件
+type Event struct {
+	Type    string         `json:"type"`
+	URL     string         `json:"url,omitempty"`     // 导航的页面地址
+	Error   string         `json:"error,omitempty"`   // 该阶段失败的原因
+	Actions []ActionResult `json:"actions,omitempty"` // 交互步骤执行结果
+	Cost    string         `json:"cost"`              // 距嗅探开始的耗时
+}
+
+// eventEmitter 返回发送事件的函数，未设置 OnEvent 时发送的事件被丢弃
+func eventEmitter(options *SnifferOptions, startTime time.Time) func(e Event, err error) {
+	return func(e Event, err error) {
+		if options.OnEvent == nil {
+			return
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		e.Cost = costString(startTime)
+		options.OnEvent(e)
+	}
+}
@@ -0,0 +1,282 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// targetTypeIframe 跨进程 iframe (OOPIF) 的目标类型
+const targetTypeIframe proto.TargetTargetInfoType = "iframe"
+
+// 查找 frame 的轮询间隔和同进程 iframe 的最大嵌套深度
+const (
+	frameFindInterval = 200 * time.Millisecond
+	frameMaxDepth     = 3
+)
+
+// frameWatcher 自动附加跨进程 iframe 和页面打开的弹出窗口，在每个目标上挂载与主页面相同的
+// 请求拦截和响应监听，并记录请求所在 frame 的地址。
+//
+// 跨进程 iframe 通过 Target.setAutoAttach 在启动前暂停，挂载完成后才恢复运行，不会漏掉第一个请求；
+// 弹出窗口在创建后附加，附加之前发出的请求无法拦截。
+type frameWatcher struct {
+	ctx   context.Context
+	page  *rod.Page
+	watch func(p *rod.Page) func() // 在目标上挂载拦截器和监听器，返回停止函数，可以为 nil
+	docs  sync.Map                 // 请求地址 -> 所在 frame 的文档地址，主框架的请求不记录
+
+	mu      sync.Mutex
+	targets []*frameTarget
+	closed  bool
+}
+
+// frameTarget 附加的跨进程 iframe 或弹出窗口
+type frameTarget struct {
+	id      proto.TargetTargetID
+	session *rod.Page // 自动附加的会话，只能用于协议调用
+	popup   *rod.Page // 弹出窗口页面
+	stop    func()
+}
+
+// watchFrames 开始自动附加 iframe 和弹出窗口，ctx 结束后停止附加新目标
+func watchFrames(ctx context.Context, page *rod.Page, watch func(p *rod.Page) func()) *frameWatcher {
+	fw := &frameWatcher{ctx: ctx, page: page, watch: watch}
+	fw.track(page, true)
+	fw.autoAttach(page)
+
+	// 弹出窗口由浏览器级事件发现，打开者可以是主页面或已附加的 iframe
+	go page.Browser().Context(ctx).EachEvent(func(e *proto.TargetTargetCreated) {
+		if e.TargetInfo.Type == proto.TargetTargetInfoTypePage && fw.isOpener(e.TargetInfo.OpenerID) {
+			go fw.attachPopup(e.TargetInfo.TargetID)
+		}
+	})()
+	return fw
+}
+
+// autoAttach 在目标上开启自动附加，并处理其子 iframe 的附加事件
+func (fw *frameWatcher) autoAttach(p *rod.Page) {
+	err := proto.TargetSetAutoAttach{AutoAttach: true, WaitForDebuggerOnStart: true, Flatten: true}.Call(p)
+	if err != nil {
+		return
+	}
+	go p.Context(fw.ctx).EachEvent(func(e *proto.TargetAttachedToTarget) {
+		fw.attachFrame(e)
+	})()
+}
+
+// attachFrame 挂载跨进程 iframe 后恢复其运行，其他类型的目标 (如 worker) 直接恢复
+func (fw *frameWatcher) attachFrame(e *proto.TargetAttachedToTarget) {
+	session := fw.page.Browser().PageFromSession(e.SessionID)
+	defer func() {
+		_ = proto.RuntimeRunIfWaitingForDebugger{}.Call(session)
+	}()
+	if e.TargetInfo.Type != targetTypeIframe {
+		return
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.closed {
+		return
+	}
+	t := &frameTarget{id: e.TargetInfo.TargetID, session: session}
+	if fw.watch != nil {
+		t.stop = fw.watch(session)
+	}
+	fw.track(session, false)
+	fw.autoAttach(session)
+	fw.targets = append(fw.targets, t)
+}
+
+// attachPopup 附加弹出窗口
+func (fw *frameWatcher) attachPopup(id proto.TargetTargetID) {
+	popup, err := fw.page.Browser().PageFromTarget(id)
+	if err != nil {
+		return
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.closed {
+		_ = popup.Close()
+		return
+	}
+	t := &frameTarget{id: id, popup: popup}
+	if fw.watch != nil {
+		t.stop = fw.watch(popup)
+	}
+	fw.track(popup, false)
+	fw.autoAttach(popup)
+	fw.targets = append(fw.targets, t)
+}
+
+// isOpener 目标是否为主页面或已附加的 iframe、弹出窗口
+func (fw *frameWatcher) isOpener(id proto.TargetTargetID) bool {
+	if id == "" {
+		return false
+	}
+	if id == fw.page.TargetID {
+		return true
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, t := range fw.targets {
+		if t.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// track 记录请求所在 frame 的文档地址，主页面只记录子 frame 的请求
+func (fw *frameWatcher) track(p *rod.Page, main bool) {
+	go p.Context(fw.ctx).EachEvent(func(e *proto.NetworkRequestWillBeSent) {
+		if main && e.FrameID == fw.page.FrameID {
+			return
+		}
+		if e.DocumentURL != "" {
+			fw.docs.LoadOrStore(e.Request.URL, e.DocumentURL)
+		}
+	})()
+}
+
+// frameURL 返回请求所在 frame 的地址，来自主框架时返回空字符串
+func (fw *frameWatcher) frameURL(reqURL string) string {
+	if doc, ok := fw.docs.Load(reqURL); ok {
+		return doc.(string)
+	}
+	return ""
+}
+
+// annotate 为候选地址填写所在 frame，从响应体中发现的地址使用所在响应的 frame
+func (fw *frameWatcher) annotate(candidates []URLWithHeaders) {
+	for i := range candidates {
+		c := &candidates[i]
+		c.Frame = fw.frameURL(c.URL)
+		if c.Frame == "" && c.Source != "" {
+			c.Frame = fw.frameURL(c.Source)
+		}
+	}
+}
+
+// find 等待并返回地址匹配 pattern 的 iframe 或弹出窗口，依次查找同进程 iframe、跨进程 iframe 和弹出窗口
+func (fw *frameWatcher) find(ctx context.Context, pattern *regexp.Regexp) (*rod.Page, error) {
+	for {
+		if p := fw.findSameProcess(ctx, fw.page, pattern, 0); p != nil {
+			return p, nil
+		}
+		if p := fw.findTarget(pattern); p != nil {
+			return p, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("未找到匹配的 frame")
+		case <-time.After(frameFindInterval):
+		}
+	}
+}
+
+// findSameProcess 在同进程 iframe 中查找，跨进程 iframe 无法在父页面中执行脚本，会被跳过
+func (fw *frameWatcher) findSameProcess(ctx context.Context, p *rod.Page, pattern *regexp.Regexp, depth int) *rod.Page {
+	if depth >= frameMaxDepth {
+		return nil
+	}
+	iframes, err := p.Context(ctx).Elements("iframe")
+	if err != nil {
+		return nil
+	}
+	for _, el := range iframes {
+		frame, err := el.Frame()
+		if err != nil {
+			continue
+		}
+		res, err := frame.Context(ctx).Eval(`() => location.href`)
+		if err != nil {
+			continue
+		}
+		if pattern.MatchString(res.Value.Str()) {
+			return frame
+		}
+		if found := fw.findSameProcess(ctx, frame, pattern, depth+1); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findTarget 在已附加的跨进程 iframe 和弹出窗口中查找
+func (fw *frameWatcher) findTarget(pattern *regexp.Regexp) *rod.Page {
+	fw.mu.Lock()
+	targets := make([]*frameTarget, len(fw.targets))
+	copy(targets, fw.targets)
+	fw.mu.Unlock()
+
+	browser := fw.page.Browser()
+	for _, t := range targets {
+		info, err := proto.TargetGetTargetInfo{TargetID: t.id}.Call(browser)
+		if err != nil || !pattern.MatchString(info.TargetInfo.URL) {
+			continue
+		}
+		if t.popup != nil {
+			return t.popup
+		}
+		// 自动附加的会话不能执行脚本，另外附加一个完整的页面实例
+		if p, err := browser.PageFromTarget(t.id); err == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// close 停止自动附加，移除各目标上的拦截器，断开 iframe 会话并关闭弹出窗口
+func (fw *frameWatcher) close() {
+	fw.mu.Lock()
+	fw.closed = true
+	targets := fw.targets
+	fw.targets = nil
+	fw.mu.Unlock()
+
+	_ = proto.TargetSetAutoAttach{AutoAttach: false, Flatten: true}.Call(fw.page)
+	for _, t := range targets {
+		if t.stop != nil {
+			t.stop()
+		}
+		if t.popup != nil {
+			_ = t.popup.Close()
+		} else {
+			_ = proto.TargetDetachFromTarget{SessionID: t.session.SessionID}.Call(fw.page.Browser())
+		}
+	}
+}
+
+// compileFrame 编译 SnifferOptions.Frame，为空时返回 nil
+func compileFrame(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: frame 正则格式错误: %v", ErrInvalidOption, err)
+	}
+	return re, nil
+}
+
+// scriptTarget 返回 css 和 script 的作用页面，未指定 frame 时为主页面，找不到匹配的 frame 时返回 nil
+func (s *Sniffer) scriptTarget(ctx context.Context, page *rod.Page, frames *frameWatcher, pattern *regexp.Regexp) *rod.Page {
+	if pattern == nil || frames == nil {
+		return page
+	}
+	target, err := frames.find(ctx, pattern)
+	if err != nil {
+		s.log("查找frame失败:", pattern, err)
+		return nil
+	}
+	return target
+}
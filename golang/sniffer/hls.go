@@ -0,0 +1,252 @@
+package sniffer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 清单校验参数
+const (
+	manifestMaxBody = 2 << 20 // 播放列表和 DASH 清单最大读取字节数
+	hlsMaxVariants  = 8       // 主播放列表最多展开的子播放列表数
+)
+
+// HLSInfo HLS 播放列表解析结果
+type HLSInfo struct {
+	Valid      bool         `json:"valid"`
+	Error      string       `json:"error,omitempty"` // 无效原因，如 HTTP 403、不是 m3u8
+	Master     bool         `json:"master"`          // 是否为主播放列表
+	Variants   []HLSVariant `json:"variants,omitempty"`
+	Segments   int          `json:"segments,omitempty"`   // 分片数，主播放列表为空
+	Duration   float64      `json:"duration,omitempty"`   // 总时长（秒）
+	Encryption string       `json:"encryption,omitempty"` // 加密方式，如 AES-128、SAMPLE-AES
+}
+
+// HLSVariant 主播放列表中的子播放列表
+type HLSVariant struct {
+	URL        string  `json:"url"`
+	Bandwidth  int     `json:"bandwidth"`
+	Resolution string  `json:"resolution,omitempty"`
+	Codecs     string  `json:"codecs,omitempty"`
+	Valid      bool    `json:"valid"`
+	Segments   int     `json:"segments,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	Encryption string  `json:"encryption,omitempty"`
+}
+
+// isHLS 候选地址是否为 m3u8
+func isHLS(c *URLWithHeaders) bool {
+	return strings.Contains(strings.ToLower(c.URL), ".m3u8") ||
+		strings.Contains(strings.ToLower(c.ContentType), "mpegurl")
+}
+
+// validateHLS 并发校验候选地址中的 m3u8，结果写入各候选的 HLS 字段
+func validateHLS(ctx context.Context, client *http.Client, candidates []URLWithHeaders) {
+	var wg sync.WaitGroup
+	for i := range candidates {
+		if !isHLS(&candidates[i]) {
+			continue
+		}
+		wg.Add(1)
+		go func(c *URLWithHeaders) {
+			defer wg.Done()
+			c.HLS = probeHLS(ctx, client, c.URL, c.Headers)
+		}(&candidates[i])
+	}
+	wg.Wait()
+}
+
+// probeHLS 下载并解析播放列表，主播放列表会继续解析各子播放列表
+func probeHLS(ctx context.Context, client *http.Client, playlistURL string, headers map[string]string) *HLSInfo {
+	info, base, err := fetchPlaylist(ctx, client, playlistURL, headers)
+	if err != nil {
+		return &HLSInfo{Error: err.Error()}
+	}
+	if !info.Master {
+		return info
+	}
+
+	// 展开子播放列表
+	var wg sync.WaitGroup
+	for i := range info.Variants {
+		if i >= hlsMaxVariants {
+			break
+		}
+		v := &info.Variants[i]
+		v.URL = resolveRef(base, v.URL)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub, _, err := fetchPlaylist(ctx, client, v.URL, headers)
+			if err != nil || sub.Master {
+				return
+			}
+			v.Valid = true
+			v.Segments = sub.Segments
+			v.Duration = sub.Duration
+			v.Encryption = sub.Encryption
+		}()
+	}
+	wg.Wait()
+	for i := hlsMaxVariants; i < len(info.Variants); i++ {
+		info.Variants[i].URL = resolveRef(base, info.Variants[i].URL)
+	}
+	return info
+}
+
+// fetchManifest 携带请求头下载播放列表或清单，返回内容和跳转后的最终地址
+func fetchManifest(ctx context.Context, client *http.Client, manifestURL string, headers map[string]string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, manifestMaxBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
+
+// fetchPlaylist 下载并解析单个播放列表，返回最终地址用于解析相对路径
+func fetchPlaylist(ctx context.Context, client *http.Client, playlistURL string, headers map[string]string) (*HLSInfo, *url.URL, error) {
+	body, base, err := fetchManifest(ctx, client, playlistURL, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := parsePlaylist(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, base, nil
+}
+
+// parsePlaylist 解析 m3u8 播放列表
+func parsePlaylist(r io.Reader) (*HLSInfo, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), manifestMaxBody)
+
+	info := &HLSInfo{}
+	header := false
+	var pending *HLSVariant
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, fmt.Errorf("不是 m3u8 播放列表")
+			}
+			header = true
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pending = &HLSVariant{
+				Bandwidth:  bandwidth,
+				Resolution: attrs["RESOLUTION"],
+				Codecs:     attrs["CODECS"],
+			}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if idx := strings.Index(value, ","); idx >= 0 {
+				value = value[:idx]
+			}
+			duration, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("分片时长格式错误: %s", line)
+			}
+			info.Segments++
+			info.Duration += duration
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			method := parseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))["METHOD"]
+			if method != "" && method != "NONE" {
+				info.Encryption = method
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			// URI 行，紧跟 EXT-X-STREAM-INF 时为子播放列表
+			if pending != nil {
+				pending.URL = line
+				info.Variants = append(info.Variants, *pending)
+				pending = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !header {
+		return nil, fmt.Errorf("播放列表为空")
+	}
+	info.Master = len(info.Variants) > 0
+	if !info.Master && info.Segments == 0 {
+		return nil, fmt.Errorf("播放列表没有分片")
+	}
+	info.Valid = true
+	return info, nil
+}
+
+// parseAttributes 解析 m3u8 属性列表，如 BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// resolveRef 将播放列表中的相对地址转换为绝对地址
+func resolveRef(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || base == nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
@@ -0,0 +1,351 @@
+package sniffer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// mseBinding 初始化脚本向服务上报事件使用的绑定函数名
+const mseBinding = "__pupSnifferMSE"
+
+// 每个 SourceBuffer 最多记录的分片数，blob 内容的最大读取长度
+const (
+	mseMaxSegments = 500
+	mseMaxBlobText = 1 << 20
+)
+
+// mseScript 在页面脚本之前注入，替换 MediaSource、SourceBuffer 和 URL.createObjectURL，
+// 记录 XHR、fetch 得到的 ArrayBuffer 与请求地址的对应关系，在 appendBuffer 时上报数据来源。
+// 播放器转封装后追加的数据无法直接对应，使用最近一次完成的二进制响应并标记为推断。
+const mseScript = `(() => {
+	const send = window[%q];
+	if (typeof send !== 'function') return;
+	try { delete window[%q]; } catch (e) {}
+
+	const prefix = Math.random().toString(36).slice(2, 8) + '-';
+	let seq = 0;
+	const nextId = () => prefix + (++seq);
+	const emit = (ev) => { try { send(JSON.stringify(ev)); } catch (e) {} };
+
+	const sources = new WeakMap();
+	let lastBinary = '';
+	const remember = (buf, url) => {
+		if (buf && url) { sources.set(buf, url); lastBinary = url; }
+	};
+
+	const xhrOpen = XMLHttpRequest.prototype.open;
+	XMLHttpRequest.prototype.open = function (method, url) {
+		this.addEventListener('load', () => {
+			try {
+				if (this.responseType === 'arraybuffer' && this.response) {
+					remember(this.response, this.responseURL || String(url));
+				}
+			} catch (e) {}
+		});
+		return xhrOpen.apply(this, arguments);
+	};
+
+	const arrayBuffer = Response.prototype.arrayBuffer;
+	Response.prototype.arrayBuffer = function () {
+		const url = this.url;
+		return arrayBuffer.apply(this, arguments).then((buf) => { remember(buf, url); return buf; });
+	};
+
+	const msIds = new WeakMap();
+	const sbIds = new WeakMap();
+	const msId = (ms) => {
+		let id = msIds.get(ms);
+		if (!id) { id = nextId(); msIds.set(ms, id); }
+		return id;
+	};
+
+	const MS = window.MediaSource;
+	if (MS) {
+		const addSourceBuffer = MS.prototype.addSourceBuffer;
+		MS.prototype.addSourceBuffer = function (type) {
+			const sb = addSourceBuffer.apply(this, arguments);
+			const id = nextId();
+			sbIds.set(sb, id);
+			emit({t: 'sb', ms: msId(this), sb: id, mime: String(type)});
+			return sb;
+		};
+	}
+
+	const SB = window.SourceBuffer;
+	if (SB) {
+		const appendBuffer = SB.prototype.appendBuffer;
+		SB.prototype.appendBuffer = function (data) {
+			try {
+				const buf = data && data.buffer ? data.buffer : data;
+				let url = sources.get(buf) || '';
+				const inferred = !url && !!lastBinary;
+				if (inferred) url = lastBinary;
+				emit({t: 'append', sb: sbIds.get(this) || '', url: url, bytes: data ? data.byteLength : 0, inferred: inferred});
+			} catch (e) {}
+			return appendBuffer.apply(this, arguments);
+		};
+	}
+
+	const createObjectURL = URL.createObjectURL;
+	URL.createObjectURL = function (obj) {
+		const url = createObjectURL.apply(this, arguments);
+		try {
+			if (MS && obj instanceof MS) {
+				emit({t: 'ms', ms: msId(obj), url: url});
+			} else if (obj instanceof Blob) {
+				const ev = {t: 'blob', url: url, type: obj.type, size: obj.size};
+				if (obj.size <= %d && (obj.type === '' || /mpegurl|dash|xml|json|text/i.test(obj.type))) {
+					obj.text().then((text) => { ev.text = text; emit(ev); }, () => emit(ev));
+				} else {
+					emit(ev);
+				}
+			}
+		} catch (e) {}
+		return url;
+	};
+})();`
+
+// MSEInfo MediaSource 捕获结果，见 SnifferOptions.CaptureMSE
+type MSEInfo struct {
+	Streams []MSEStream `json:"streams"`
+	Blobs   []MSEBlob   `json:"blobs,omitempty"`
+}
+
+// MSEStream 一个 MediaSource 及其 SourceBuffer
+type MSEStream struct {
+	BlobURL       string            `json:"blob_url,omitempty"` // 赋给 video.src 的 blob: 地址
+	SourceBuffers []MSESourceBuffer `json:"source_buffers"`
+}
+
+// MSESourceBuffer SourceBuffer 及追加到其中的数据来源
+type MSESourceBuffer struct {
+	MimeType string       `json:"mime_type"`
+	Codecs   string       `json:"codecs,omitempty"`
+	Appends  int          `json:"appends"`            // 追加次数
+	Unknown  int          `json:"unknown,omitempty"`  // 无法对应网络请求的追加次数
+	Segments []MSESegment `json:"segments,omitempty"` // 按追加顺序，连续追加同一地址时合并
+	Init     string       `json:"init,omitempty"`     // 初始化分片地址
+	Template string       `json:"template,omitempty"` // 分片地址模板，序号替换为 $Number$
+}
+
+// MSESegment 追加到 SourceBuffer 的网络数据
+type MSESegment struct {
+	URL      string `json:"url"`
+	Bytes    int    `json:"bytes"`
+	Appends  int    `json:"appends"`
+	Inferred bool   `json:"inferred,omitempty"` // 数据经过播放器转封装，地址按最近完成的请求推断
+}
+
+// MSEBlob 通过 URL.createObjectURL 创建的 Blob 地址，文本类型的 Blob (如 m3u8) 附带内容
+type MSEBlob struct {
+	URL     string `json:"url"`
+	Type    string `json:"type,omitempty"`
+	Size    int    `json:"size"`
+	Content string `json:"content,omitempty"`
+}
+
+// mseEvent 初始化脚本上报的事件
+type mseEvent struct {
+	T        string `json:"t"`
+	MS       string `json:"ms"`
+	SB       string `json:"sb"`
+	URL      string `json:"url"`
+	Mime     string `json:"mime"`
+	Bytes    int    `json:"bytes"`
+	Inferred bool   `json:"inferred"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	Text     string `json:"text"`
+}
+
+// mseCapture 汇总主页面和各 iframe 上报的 MSE 事件
+type mseCapture struct {
+	mu      sync.Mutex
+	streams []*mseStream
+	byMS    map[string]*mseStream
+	bySB    map[string]*MSESourceBuffer
+	blobs   []MSEBlob
+}
+
+type mseStream struct {
+	blobURL string
+	buffers []*MSESourceBuffer
+}
+
+func newMSECapture() *mseCapture {
+	return &mseCapture{
+		byMS: make(map[string]*mseStream),
+		bySB: make(map[string]*MSESourceBuffer),
+	}
+}
+
+// watch 在目标上注入初始化脚本并接收上报事件，返回移除函数，需在导航之前调用
+func (m *mseCapture) watch(ctx context.Context, p *rod.Page) func() {
+	if err := (proto.RuntimeAddBinding{Name: mseBinding}).Call(p); err != nil {
+		return func() {}
+	}
+	script := fmt.Sprintf(mseScript, mseBinding, mseBinding, mseMaxBlobText)
+	res, err := proto.PageAddScriptToEvaluateOnNewDocument{Source: script}.Call(p)
+
+	go p.Context(ctx).EachEvent(func(e *proto.RuntimeBindingCalled) {
+		if e.Name == mseBinding {
+			m.handle(e.Payload)
+		}
+	})()
+
+	return func() {
+		if err == nil {
+			_ = proto.PageRemoveScriptToEvaluateOnNewDocument{Identifier: res.Identifier}.Call(p)
+		}
+		_ = proto.RuntimeRemoveBinding{Name: mseBinding}.Call(p)
+	}
+}
+
+// stream 返回 MediaSource 对应的记录，不存在时创建
+func (m *mseCapture) stream(id string) *mseStream {
+	st, ok := m.byMS[id]
+	if !ok {
+		st = &mseStream{}
+		m.byMS[id] = st
+		m.streams = append(m.streams, st)
+	}
+	return st
+}
+
+// handle 处理一条上报事件
+func (m *mseCapture) handle(payload string) {
+	var e mseEvent
+	if json.Unmarshal([]byte(payload), &e) != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.T {
+	case "ms":
+		m.stream(e.MS).blobURL = e.URL
+	case "sb":
+		sb := &MSESourceBuffer{MimeType: e.Mime}
+		if mediaType, params, err := mime.ParseMediaType(e.Mime); err == nil {
+			sb.MimeType, sb.Codecs = mediaType, params["codecs"]
+		}
+		st := m.stream(e.MS)
+		st.buffers = append(st.buffers, sb)
+		m.bySB[e.SB] = sb
+	case "append":
+		sb, ok := m.bySB[e.SB]
+		if !ok {
+			return
+		}
+		sb.Appends++
+		if e.URL == "" {
+			sb.Unknown++
+			return
+		}
+		if n := len(sb.Segments); n > 0 && sb.Segments[n-1].URL == e.URL {
+			sb.Segments[n-1].Bytes += e.Bytes
+			sb.Segments[n-1].Appends++
+			return
+		}
+		if len(sb.Segments) < mseMaxSegments {
+			sb.Segments = append(sb.Segments, MSESegment{URL: e.URL, Bytes: e.Bytes, Appends: 1, Inferred: e.Inferred})
+		}
+	case "blob":
+		blob := MSEBlob{URL: e.URL, Type: e.Type, Size: e.Size}
+		// 类型为空的 Blob 只保留清单内容
+		if e.Type != "" || looksLikeManifest(e.Text) {
+			blob.Content = e.Text
+		}
+		m.blobs = append(m.blobs, blob)
+	}
+}
+
+// looksLikeManifest 文本是否为 m3u8 或 DASH 清单
+func looksLikeManifest(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "#EXTM3U") || strings.Contains(text, "<MPD")
+}
+
+// result 返回捕获结果，没有任何 MediaSource 和 Blob 时返回 nil
+func (m *mseCapture) result() *MSEInfo {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.streams) == 0 && len(m.blobs) == 0 {
+		return nil
+	}
+
+	info := &MSEInfo{Streams: make([]MSEStream, 0, len(m.streams)), Blobs: append([]MSEBlob(nil), m.blobs...)}
+	for _, st := range m.streams {
+		stream := MSEStream{BlobURL: st.blobURL, SourceBuffers: make([]MSESourceBuffer, 0, len(st.buffers))}
+		for _, sb := range st.buffers {
+			buffer := *sb
+			buffer.Segments = append([]MSESegment(nil), sb.Segments...)
+			urls := make([]string, len(sb.Segments))
+			for i, seg := range sb.Segments {
+				urls[i] = seg.URL
+			}
+			buffer.Template, buffer.Init = segmentTemplate(urls)
+			stream.SourceBuffers = append(stream.SourceBuffers, buffer)
+		}
+		info.Streams = append(info.Streams, stream)
+	}
+	return info
+}
+
+// digitRuns 地址中的连续数字
+var digitRuns = regexp.MustCompile(`\d+`)
+
+// segmentTemplate 从按顺序追加的分片地址推断地址模板：除序号外完全相同的地址中，
+// 唯一变化的数字替换为 $Number$。第一个地址与模板不符时作为初始化分片返回。
+func segmentTemplate(urls []string) (template, init string) {
+	if len(urls) >= 3 {
+		if t := numberTemplate(urls[1:]); t != "" {
+			if numberTemplate([]string{urls[0], urls[1]}) == t {
+				return t, ""
+			}
+			return t, urls[0]
+		}
+	}
+	return numberTemplate(urls), ""
+}
+
+// numberTemplate 所有地址只有同一位置的数字不同时返回模板，否则返回空字符串
+func numberTemplate(urls []string) string {
+	if len(urls) < 2 {
+		return ""
+	}
+	skeleton := digitRuns.ReplaceAllString(urls[0], "#")
+	first := digitRuns.FindAllStringIndex(urls[0], -1)
+	varying := -1
+	for _, u := range urls[1:] {
+		if digitRuns.ReplaceAllString(u, "#") != skeleton {
+			return ""
+		}
+		a, b := digitRuns.FindAllString(urls[0], -1), digitRuns.FindAllString(u, -1)
+		for i := range a {
+			if a[i] == b[i] {
+				continue
+			}
+			if varying >= 0 && varying != i {
+				return ""
+			}
+			varying = i
+		}
+	}
+	if varying < 0 {
+		return ""
+	}
+	loc := first[varying]
+	return urls[0][:loc[0]] + "$Number$" + urls[0][loc[1]:]
+}
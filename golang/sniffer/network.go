@@ -0,0 +1,142 @@
+package sniffer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// bodyURLRegex 响应体中的 URL，JSON 转义的斜杠需先还原
+var bodyURLRegex = regexp.MustCompile(`https?://[^\s"'<>\\]+`)
+
+// bodyUnescaper 还原 JSON 和 HTML 中常见的转义
+var bodyUnescaper = strings.NewReplacer(`\/`, `/`, `\u0026`, `&`, `\u002F`, `/`, `\u002f`, `/`, `&amp;`, `&`)
+
+// scanTypes 需要扫描响应体的资源类型
+var scanTypes = map[proto.NetworkResourceType]bool{
+	proto.NetworkResourceTypeXHR:      true,
+	proto.NetworkResourceTypeFetch:    true,
+	proto.NetworkResourceTypeDocument: true,
+}
+
+// scanResponseBodies 扫描 XHR、fetch 和文档响应体中的 URL，直到 ctx 结束。
+// 超过 maxSize 字节或非文本的响应会被跳过，found 的 source 为 URL 所在响应的地址。
+func (s *Sniffer) scanResponseBodies(ctx context.Context, page *rod.Page, maxSize int, found func(bodyURL, source string)) {
+	var mu sync.Mutex
+	pending := make(map[proto.NetworkRequestID]string)
+
+	wait := page.Context(ctx).EachEvent(
+		func(e *proto.NetworkResponseReceived) {
+			if !scanTypes[e.Type] || e.Response == nil || !isTextMIME(e.Response.MIMEType) {
+				return
+			}
+			mu.Lock()
+			pending[e.RequestID] = e.Response.URL
+			mu.Unlock()
+		},
+		func(e *proto.NetworkLoadingFinished) {
+			mu.Lock()
+			source, ok := pending[e.RequestID]
+			delete(pending, e.RequestID)
+			mu.Unlock()
+			if !ok || int(e.EncodedDataLength) > maxSize {
+				return
+			}
+
+			// 读取响应体需要等待浏览器应答，不能阻塞事件循环
+			go func() {
+				res, err := proto.NetworkGetResponseBody{RequestID: e.RequestID}.Call(page.Context(ctx))
+				if err != nil || res.Base64Encoded || len(res.Body) > maxSize {
+					return
+				}
+				for _, u := range bodyURLRegex.FindAllString(bodyUnescaper.Replace(res.Body), -1) {
+					if u != source {
+						found(u, source)
+					}
+				}
+			}()
+		},
+	)
+	go wait()
+}
+
+// mediaResponse 浏览器收到的媒体响应
+type mediaResponse struct {
+	URL         string
+	ContentType string
+}
+
+// watchMediaResponses 根据浏览器收到的响应头识别媒体地址，不发起额外请求，直到 ctx 结束
+func watchMediaResponses(ctx context.Context, page *rod.Page, found func(mediaResponse)) {
+	wait := page.Context(ctx).EachEvent(func(e *proto.NetworkResponseReceived) {
+		if e.Response == nil || !strings.HasPrefix(e.Response.URL, "http") {
+			return
+		}
+		disposition := ""
+		for k, v := range e.Response.Headers {
+			if strings.EqualFold(k, "content-disposition") {
+				disposition = v.String()
+			}
+		}
+		if isMediaResponse(e.Response.URL, e.Response.MIMEType, disposition) {
+			found(mediaResponse{URL: e.Response.URL, ContentType: e.Response.MIMEType})
+		}
+	})
+	go wait()
+}
+
+// segmentExts 媒体分片扩展名，分片本身不作为播放地址
+var segmentExts = []string{".ts", ".m4s", ".cmfv", ".cmfa"}
+
+// isMediaResponse 根据响应类型判断是否为媒体地址：音视频、m3u8 和 DASH 清单，排除 HLS/DASH 分片
+func isMediaResponse(urlStr, contentType, contentDisposition string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+
+	switch {
+	case contentType == "application/vnd.apple.mpegurl", contentType == "application/x-mpegurl",
+		contentType == "audio/mpegurl", contentType == "audio/x-mpegurl":
+		return true
+	case isManifestResponse(contentType, contentDisposition):
+		return true
+	case strings.HasPrefix(contentType, "video/"), strings.HasPrefix(contentType, "audio/"):
+		if contentType == "video/mp2t" || contentType == "video/iso.segment" {
+			return false
+		}
+		path := strings.ToLower(urlStr)
+		if idx := strings.IndexAny(path, "?#"); idx >= 0 {
+			path = path[:idx]
+		}
+		for _, ext := range segmentExts {
+			if strings.HasSuffix(path, ext) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isBodyMediaURL 判断响应体中发现的地址是否为媒体地址，规则与请求拦截一致
+func (s *Sniffer) isBodyMediaURL(u string, customRegex, excludeRegex *regexp.Regexp) bool {
+	if excludeRegex != nil && excludeRegex.MatchString(u) {
+		return false
+	}
+	if customRegex != nil && customRegex.MatchString(u) {
+		return true
+	}
+	return s.urlRegex.MatchString(u) && s.IsRealURLCheck(u) && isPlayableURL(u)
+}
+
+// isTextMIME 是否为可能包含 URL 的文本类型
+func isTextMIME(mime string) bool {
+	mime = strings.ToLower(mime)
+	return strings.HasPrefix(mime, "text/") || strings.Contains(mime, "json") ||
+		strings.Contains(mime, "javascript") || strings.Contains(mime, "xml")
+}
@@ -0,0 +1,216 @@
+package sniffer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// PoolStats 页面池状态
+type PoolStats struct {
+	Capacity int   `json:"capacity"` // 最大并发页面数
+	InUse    int   `json:"in_use"`   // 正在使用的页面数
+	Idle     int   `json:"idle"`     // 空闲待复用的页面数
+	Waiting  int   `json:"waiting"`  // 排队等待的请求数
+	Created  int64 `json:"created"`  // 累计创建页面数
+	Reused   int64 `json:"reused"`   // 累计复用页面数
+	Timeouts int64 `json:"timeouts"` // 累计排队超时次数
+}
+
+// pooledPage 池中的页面
+type pooledPage struct {
+	page       *rod.Page
+	owner      *browserInstance
+	generation int
+	uses       int
+	contextID  proto.BrowserBrowserContextID // 非空表示页面位于独立上下文中，用完即销毁
+	dedicated  bool                          // 会话等专用页面，用完即关闭
+}
+
+// pagePool 有界页面池，限制同时打开的页面数并复用已预热的页面
+type pagePool struct {
+	mu           sync.Mutex
+	slots        chan struct{}
+	idle         []*pooledPage
+	inUse        map[proto.TargetTargetID]*pooledPage
+	waiting      int
+	created      int64
+	reused       int64
+	timeouts     int64
+	queueTimeout time.Duration
+	maxUses      int
+	newPage      func(opts *contextOptions) (*pooledPage, error)
+	log          func(args ...interface{})
+}
+
+// newPagePool 创建页面池
+func newPagePool(capacity int, queueTimeout time.Duration, maxUses int, newPage func(opts *contextOptions) (*pooledPage, error), log func(args ...interface{})) *pagePool {
+	return &pagePool{
+		slots:        make(chan struct{}, capacity),
+		inUse:        make(map[proto.TargetTargetID]*pooledPage),
+		queueTimeout: queueTimeout,
+		maxUses:      maxUses,
+		newPage:      newPage,
+		log:          log,
+	}
+}
+
+// acquire 获取页面，池满时排队等待，超过排队时间返回 ErrQueueTimeout。
+// opts 不为空时在独立上下文中新建页面，不复用空闲页面，但同样占用并发槽位。
+func (p *pagePool) acquire(ctx context.Context, opts *contextOptions) (*rod.Page, error) {
+	p.mu.Lock()
+	p.waiting++
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = ErrQueueTimeout
+	}
+
+	p.mu.Lock()
+	p.waiting--
+	if err == ErrQueueTimeout {
+		p.timeouts++
+	}
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	// 优先复用空闲页面，丢弃属于已崩溃浏览器进程的页面
+	for opts == nil && len(p.idle) > 0 {
+		n := len(p.idle)
+		pp := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		if !pp.owner.alive(pp.generation) {
+			continue
+		}
+		pp.uses++
+		p.reused++
+		p.inUse[pp.page.TargetID] = pp
+		p.mu.Unlock()
+		return pp.page, nil
+	}
+	// 独立上下文的页面不复用空闲页面，新建前关闭最早的空闲页面，
+	// 保证打开的页面数 (每个槽位至多一个页面加上空闲页面) 不超过并发上限
+	var trimmed []*pooledPage
+	for len(p.idle) > 0 && len(p.slots)+len(p.idle) > cap(p.slots) {
+		trimmed = append(trimmed, p.idle[0])
+		p.idle = p.idle[1:]
+	}
+	p.mu.Unlock()
+	for _, pp := range trimmed {
+		if pp.owner.alive(pp.generation) {
+			p.discard(pp)
+		}
+	}
+
+	pp, err := p.newPage(opts)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	pp.uses = 1
+
+	p.mu.Lock()
+	p.created++
+	p.inUse[pp.page.TargetID] = pp
+	p.mu.Unlock()
+	return pp.page, nil
+}
+
+// release 归还页面，重置成功的页面放回空闲列表，否则直接关闭。discard 为 true 时不复用
+func (p *pagePool) release(page *rod.Page, discard bool) {
+	p.mu.Lock()
+	pp, ok := p.inUse[page.TargetID]
+	delete(p.inUse, page.TargetID)
+	p.mu.Unlock()
+
+	if !ok {
+		// 不属于页面池的页面直接关闭
+		p.closePage(page)
+		return
+	}
+	defer func() { <-p.slots }()
+
+	// 浏览器进程已重启，旧页面随进程一起消失
+	if !pp.owner.alive(pp.generation) {
+		return
+	}
+	if discard || pp.contextID != "" || pp.dedicated || pp.uses >= p.maxUses {
+		p.discard(pp)
+		return
+	}
+	if err := resetPage(page); err != nil {
+		p.log("重置页面失败:", err)
+		p.discard(pp)
+		return
+	}
+
+	p.mu.Lock()
+	p.idle = append(p.idle, pp)
+	p.mu.Unlock()
+}
+
+// discard 关闭池中页面并更新所属浏览器的页面计数，独立上下文随页面一起销毁
+func (p *pagePool) discard(pp *pooledPage) {
+	p.closePage(pp.page)
+	if pp.contextID != "" {
+		if browser, generation := pp.owner.current(); browser != nil && generation == pp.generation {
+			err := proto.TargetDisposeBrowserContext{BrowserContextID: pp.contextID}.Call(browser)
+			if err != nil {
+				p.log("销毁浏览器上下文失败:", err)
+			}
+		}
+	}
+	pp.owner.pageClosed(pp.generation)
+}
+
+// closePage 关闭页面
+func (p *pagePool) closePage(page *rod.Page) {
+	if err := page.Close(); err != nil {
+		p.log("关闭页面失败:", err)
+	}
+}
+
+// stats 返回页面池状态
+func (p *pagePool) stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Capacity: cap(p.slots),
+		InUse:    len(p.inUse),
+		Idle:     len(p.idle),
+		Waiting:  p.waiting,
+		Created:  p.created,
+		Reused:   p.reused,
+		Timeouts: p.timeouts,
+	}
+}
+
+// resetPage 清理上一个任务留下的状态，使页面可以被下一个任务复用
+func resetPage(page *rod.Page) error {
+	page = page.Timeout(5 * time.Second)
+	defer page.CancelTimeout()
+
+	if _, err := (proto.PageNavigate{URL: "about:blank"}).Call(page); err != nil {
+		return err
+	}
+	if err := (proto.NetworkSetExtraHTTPHeaders{Headers: proto.NetworkHeaders{}}).Call(page); err != nil {
+		return err
+	}
+	if err := (proto.EmulationSetTouchEmulationEnabled{Enabled: false}).Call(page); err != nil {
+		return err
+	}
+	return proto.EmulationClearDeviceMetricsOverride{}.Call(page)
+}
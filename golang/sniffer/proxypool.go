@@ -0,0 +1,245 @@
+package sniffer
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 代理池默认参数
+const (
+	DefaultProxyStickyTTL  = 10 * time.Minute // 同一域名固定使用同一代理的时长
+	DefaultProxyQuarantine = 5 * time.Minute  // 代理连续失败后的隔离时长
+	DefaultProxyMaxFails   = 3                // 触发隔离的连续失败次数
+)
+
+// ProxyStats 单个代理的评分状态
+type ProxyStats struct {
+	Proxy            string  `json:"proxy"` // 已隐藏密码
+	Score            float64 `json:"score"`
+	Successes        int     `json:"successes"`
+	Failures         int     `json:"failures"`
+	ConsecutiveFails int     `json:"consecutive_fails"`
+	AvgLatency       int64   `json:"avg_latency_ms"`
+	Sticky           int     `json:"sticky_domains"` // 当前固定到该代理的域名数
+	Quarantined      bool    `json:"quarantined"`
+	QuarantinedUntil string  `json:"quarantined_until,omitempty"`
+}
+
+// poolProxy 代理池中的代理
+type poolProxy struct {
+	url              *url.URL
+	successes        int
+	failures         int
+	consecutiveFails int
+	avgLatency       time.Duration
+	quarantinedUntil time.Time
+}
+
+// score 综合成功率和延迟的评分，范围 (0, 1)
+func (p *poolProxy) score() float64 {
+	// 拉普拉斯平滑，新代理初始成功率为 0.5
+	rate := float64(p.successes+1) / float64(p.successes+p.failures+2)
+	return rate / (1 + p.avgLatency.Seconds()/5)
+}
+
+// stickyEntry 域名与代理的绑定
+type stickyEntry struct {
+	proxy   *poolProxy
+	expires time.Time
+}
+
+// ProxyPool 轮换代理池，按成功率和延迟评分选择代理，
+// 连续失败的代理会被隔离一段时间，同一域名在会话窗口内固定使用同一代理
+type ProxyPool struct {
+	mu         sync.Mutex
+	proxies    []*poolProxy
+	sticky     map[string]*stickyEntry
+	nextPrune  time.Time // 下次清理过期域名绑定的时间
+	stickyTTL  time.Duration
+	quarantine time.Duration
+	maxFails   int
+	rand       *rand.Rand
+}
+
+// NewProxyPool 创建代理池，stickyTTL 和 quarantine 为 0 时使用默认值
+func NewProxyPool(proxies []string, stickyTTL, quarantine time.Duration) (*ProxyPool, error) {
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("%w: 代理列表为空", ErrInvalidOption)
+	}
+	if stickyTTL <= 0 {
+		stickyTTL = DefaultProxyStickyTTL
+	}
+	if quarantine <= 0 {
+		quarantine = DefaultProxyQuarantine
+	}
+
+	pool := &ProxyPool{
+		sticky:     make(map[string]*stickyEntry),
+		stickyTTL:  stickyTTL,
+		quarantine: quarantine,
+		maxFails:   DefaultProxyMaxFails,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, raw := range proxies {
+		u, err := ParseProxy(raw)
+		if err != nil {
+			return nil, fmt.Errorf("代理 %q: %w", raw, err)
+		}
+		pool.proxies = append(pool.proxies, &poolProxy{url: u})
+	}
+	return pool, nil
+}
+
+// Pick 为域名选择代理，会话窗口内优先沿用已绑定的代理
+func (p *ProxyPool) Pick(domain string) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.pruneLocked(now)
+	if e, ok := p.sticky[domain]; ok {
+		if now.Before(e.expires) && !e.proxy.quarantined(now) {
+			return e.proxy.url
+		}
+		delete(p.sticky, domain)
+	}
+
+	picked := p.pickLocked(now)
+	p.sticky[domain] = &stickyEntry{proxy: picked, expires: now.Add(p.stickyTTL)}
+	return picked.url
+}
+
+// pruneLocked 每隔一个会话窗口清理一次过期的域名绑定，避免访问过的域名无限累积
+func (p *ProxyPool) pruneLocked(now time.Time) {
+	if now.Before(p.nextPrune) {
+		return
+	}
+	for domain, e := range p.sticky {
+		if !now.Before(e.expires) {
+			delete(p.sticky, domain)
+		}
+	}
+	p.nextPrune = now.Add(p.stickyTTL)
+}
+
+// pickLocked 按评分加权随机选择未隔离的代理，全部被隔离时选择最早解除隔离的代理
+func (p *ProxyPool) pickLocked(now time.Time) *poolProxy {
+	var candidates []*poolProxy
+	var total float64
+	for _, px := range p.proxies {
+		if !px.quarantined(now) {
+			candidates = append(candidates, px)
+			total += px.score()
+		}
+	}
+
+	if len(candidates) == 0 {
+		earliest := p.proxies[0]
+		for _, px := range p.proxies[1:] {
+			if px.quarantinedUntil.Before(earliest.quarantinedUntil) {
+				earliest = px
+			}
+		}
+		return earliest
+	}
+
+	r := p.rand.Float64() * total
+	for _, px := range candidates {
+		r -= px.score()
+		if r <= 0 {
+			return px
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// Report 记录一次使用结果，连续失败达到阈值时隔离代理并解除其域名绑定
+func (p *ProxyPool) Report(proxy *url.URL, success bool, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	px := p.find(proxy)
+	if px == nil {
+		return
+	}
+
+	if success {
+		px.successes++
+		px.consecutiveFails = 0
+		// 指数移动平均
+		if px.avgLatency == 0 {
+			px.avgLatency = latency
+		} else {
+			px.avgLatency = (px.avgLatency*4 + latency) / 5
+		}
+		return
+	}
+
+	px.failures++
+	px.consecutiveFails++
+	if px.consecutiveFails >= p.maxFails {
+		px.quarantinedUntil = time.Now().Add(p.quarantine)
+		px.consecutiveFails = 0
+		for domain, e := range p.sticky {
+			if e.proxy == px {
+				delete(p.sticky, domain)
+			}
+		}
+	}
+}
+
+// find 查找代理池中的代理
+func (p *ProxyPool) find(proxy *url.URL) *poolProxy {
+	if proxy == nil {
+		return nil
+	}
+	for _, px := range p.proxies {
+		if px.url.String() == proxy.String() {
+			return px
+		}
+	}
+	return nil
+}
+
+// Stats 返回代理池状态，按评分从高到低排序
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	sticky := make(map[*poolProxy]int)
+	for _, e := range p.sticky {
+		if now.Before(e.expires) {
+			sticky[e.proxy]++
+		}
+	}
+
+	stats := make([]ProxyStats, 0, len(p.proxies))
+	for _, px := range p.proxies {
+		st := ProxyStats{
+			Proxy:            px.url.Redacted(),
+			Score:            px.score(),
+			Successes:        px.successes,
+			Failures:         px.failures,
+			ConsecutiveFails: px.consecutiveFails,
+			AvgLatency:       px.avgLatency.Milliseconds(),
+			Sticky:           sticky[px],
+			Quarantined:      px.quarantined(now),
+		}
+		if st.Quarantined {
+			st.QuarantinedUntil = px.quarantinedUntil.Format(time.RFC3339)
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Score > stats[j].Score })
+	return stats
+}
+
+// quarantined 是否处于隔离期
+func (p *poolProxy) quarantined(now time.Time) bool {
+	return now.Before(p.quarantinedUntil)
+}
@@ -0,0 +1,171 @@
+package sniffer
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// adKeywords 广告和统计服务的域名或路径关键字，足够长不会误匹配，按子串匹配
+var adKeywords = []string{
+	"doubleclick", "googlesyndication", "googleadservices", "adservice", "adsystem", "adnxs",
+	"advert", "preroll", "pre-roll", "guanggao", "adcdn", "cnzz", "umeng", "mmstat", "miaozhen", "admaster",
+}
+
+// adSegments 较短的广告关键字，只匹配完整的域名标签或路径段，避免 uploads.、threads. 等误匹配。
+// 带点的关键字匹配连续的域名标签
+var adSegments = []string{"ad", "ads", "adx", "tanx", "hm.baidu", "pos.baidu"}
+
+// tokenParams 签名和鉴权参数，带有这些参数的地址通常是正片
+var tokenParams = []string{
+	"token", "sign", "signature", "auth", "auth_key", "authkey", "expires", "expire",
+	"policy", "key-pair-id", "wssecret", "wstime", "txsecret", "txtime",
+}
+
+// weakTokenParams 名称较通用的鉴权参数，值像签名 (足够长且不是纯数字) 时才计分，
+// 避免 ?key=1、?st=0 之类的普通参数
+var weakTokenParams = []string{"key", "st"}
+
+// matchAdKeyword 返回地址命中的广告关键字，未命中时返回空字符串
+func matchAdKeyword(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	lower := host + strings.ToLower(u.Path)
+	for _, kw := range adKeywords {
+		if strings.Contains(lower, kw) {
+			return kw
+		}
+	}
+
+	labels := "." + host + "."
+	segments := strings.Split(strings.ToLower(u.Path), "/")
+	for _, kw := range adSegments {
+		if strings.Contains(labels, "."+kw+".") {
+			return kw
+		}
+		for _, seg := range segments {
+			if seg == kw {
+				return kw
+			}
+		}
+	}
+	return ""
+}
+
+// matchTokenParam 返回地址带有的鉴权参数名，没有时返回空字符串
+func matchTokenParam(query url.Values) string {
+	for _, p := range tokenParams {
+		if query.Has(p) {
+			return p
+		}
+	}
+	for _, p := range weakTokenParams {
+		if looksLikeToken(query.Get(p)) {
+			return p
+		}
+	}
+	return ""
+}
+
+// looksLikeToken 参数值是否像签名或令牌
+func looksLikeToken(v string) bool {
+	if len(v) < 16 {
+		return false
+	}
+	return strings.Trim(v, "0123456789") != ""
+}
+
+// scoreCandidate 根据媒体类型、域名、鉴权参数、时长和发现顺序为候选地址打分
+func scoreCandidate(c *URLWithHeaders, index int) {
+	score := 0.0
+	var reasons []string
+	add := func(points float64, reason string) {
+		score += points
+		reasons = append(reasons, fmt.Sprintf("%s %+g", reason, points))
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		c.Score, c.Reasons = -100, []string{"地址无法解析 -100"}
+		return
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	contentType := strings.ToLower(c.ContentType)
+
+	if c.custom {
+		add(50, "匹配自定义正则")
+	}
+
+	// 媒体类型
+	switch {
+	case c.HLS != nil && c.HLS.Master:
+		add(40, "主播放列表")
+	case ext == ".m3u8" || strings.Contains(contentType, "mpegurl"):
+		add(30, "m3u8")
+	case ext == ".mpd" || strings.Contains(contentType, "dash+xml"):
+		add(30, "DASH 清单")
+	case ext == ".mp4" || ext == ".flv" || ext == ".mkv" || ext == ".avi" || ext == ".wmv" || ext == ".mpg" ||
+		strings.HasPrefix(contentType, "video/"):
+		add(20, "视频文件")
+	case ext == ".mp3" || ext == ".m4a" || strings.HasPrefix(contentType, "audio/"):
+		add(10, "音频文件")
+	}
+
+	// 广告和统计域名
+	if kw := matchAdKeyword(u); kw != "" {
+		add(-50, "疑似广告("+kw+")")
+	}
+
+	// 鉴权参数
+	if p := matchTokenParam(u.Query()); p != "" {
+		add(10, "带鉴权参数("+p+")")
+	}
+
+	// 播放时长，短片通常是广告
+	duration, valid := 0.0, true
+	switch {
+	case c.HLS != nil:
+		duration, valid = c.HLS.Duration, c.HLS.Valid
+		if c.HLS.Master {
+			for _, v := range c.HLS.Variants {
+				if v.Duration > duration {
+					duration = v.Duration
+				}
+			}
+		}
+	case c.DASH != nil:
+		duration, valid = c.DASH.Duration, c.DASH.Valid
+	}
+	switch {
+	case !valid:
+		add(-30, "清单无效")
+	case duration >= 600:
+		add(20, "时长超过 10 分钟")
+	case duration >= 60:
+		add(10, "时长超过 1 分钟")
+	case duration > 0 && duration < 30:
+		add(-20, "时长不足 30 秒")
+	}
+
+	// 发现顺序，越早发现越可能是正片
+	if index > 0 {
+		penalty := float64(index) * 2
+		if penalty > 10 {
+			penalty = 10
+		}
+		add(-penalty, fmt.Sprintf("第 %d 个发现", index+1))
+	}
+
+	c.Score, c.Reasons = score, reasons
+}
+
+// rankCandidates 为所有候选地址打分，按得分从高到低排序，得分相同时保持发现顺序
+func rankCandidates(candidates []URLWithHeaders) {
+	for i := range candidates {
+		scoreCandidate(&candidates[i], i)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+}
@@ -0,0 +1,406 @@
+package sniffer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// sessionNameRegex 会话名称只允许字母、数字、下划线和短横线，避免路径穿越
+var sessionNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SessionInfo 会话信息
+type SessionInfo struct {
+	Name       string `json:"name"`
+	Dir        string `json:"dir"`
+	Running    bool   `json:"running"` // 会话浏览器进程是否在运行
+	Pages      int    `json:"pages"`
+	UpdatedAt  string `json:"updated_at"` // 会话目录最后修改时间
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// session 命名持久会话，对应一个使用独立用户数据目录的浏览器进程，
+// Cookie、localStorage、IndexedDB 等数据保存在目录中，跨请求和重启保留
+type session struct {
+	name      string
+	inst      *browserInstance
+	lastUsed  time.Time
+	starting  chan struct{} // 非空表示浏览器正在启动，启动结束后关闭
+	archiving bool          // 正在打包会话目录，期间不能启动浏览器
+}
+
+// sessionManager 会话管理器，会话浏览器按需启动，空闲一段时间后关闭
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// sessionDir 会话的用户数据目录
+func (s *Sniffer) sessionDir(name string) string {
+	return filepath.Join(s.config.SessionDir, name)
+}
+
+// checkSessionName 校验会话名称
+func checkSessionName(name string) error {
+	if !sessionNameRegex.MatchString(name) {
+		return fmt.Errorf("%w: 会话名称只能包含字母、数字、下划线和短横线", ErrInvalidOption)
+	}
+	return nil
+}
+
+// sessionInstance 获取会话的浏览器实例，未运行时自动启动，会话目录不存在时自动创建。
+// 启动浏览器时不持有会话表的锁，同一会话的其他请求等待启动结束，不影响其他会话
+func (s *Sniffer) sessionInstance(name string) (*browserInstance, error) {
+	if err := checkSessionName(name); err != nil {
+		return nil, err
+	}
+
+	for {
+		s.sessions.mu.Lock()
+		sess, ok := s.sessions.sessions[name]
+		if !ok {
+			dir := s.sessionDir(name)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				s.sessions.mu.Unlock()
+				return nil, fmt.Errorf("创建会话目录失败: %v", err)
+			}
+			sess = s.newSession(name)
+		}
+		if sess.archiving {
+			s.sessions.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s 正在导出", ErrSessionBusy, name)
+		}
+		sess.lastUsed = time.Now()
+
+		if starting := sess.starting; starting != nil {
+			s.sessions.mu.Unlock()
+			<-starting
+			continue
+		}
+		if sess.inst.load() >= 0 {
+			s.sessions.mu.Unlock()
+			return sess.inst, nil
+		}
+		if s.closed() {
+			s.sessions.mu.Unlock()
+			return nil, ErrBrowserNotReady
+		}
+		starting := make(chan struct{})
+		sess.starting = starting
+		s.sessions.mu.Unlock()
+
+		err := sess.inst.start(s)
+
+		s.sessions.mu.Lock()
+		sess.starting = nil
+		s.sessions.mu.Unlock()
+		close(starting)
+
+		if err != nil {
+			return nil, err
+		}
+		// 启动期间嗅探器已关闭时，关闭刚启动的进程
+		if s.closed() {
+			sess.inst.close()
+			return nil, ErrBrowserNotReady
+		}
+		s.log("会话浏览器已启动:", name)
+		return sess.inst, nil
+	}
+}
+
+// newSession 登记会话，浏览器尚未启动。调用方需持有锁
+func (s *Sniffer) newSession(name string) *session {
+	sess := &session{name: name, inst: &browserInstance{id: -1, userDataDir: s.sessionDir(name)}}
+	if proxy, err := s.resolveProxy(&SnifferOptions{}); err == nil {
+		sess.inst.proxy = proxy
+	}
+	s.sessions.sessions[name] = sess
+	return sess
+}
+
+// CreateSession 创建会话，可选导入 Cookie（需指定 domain）
+func (s *Sniffer) CreateSession(name string, cookies []Cookie) (SessionInfo, error) {
+	if err := checkSessionName(name); err != nil {
+		return SessionInfo{}, err
+	}
+	for _, c := range cookies {
+		if c.Domain == "" {
+			return SessionInfo{}, fmt.Errorf("%w: 导入会话的 cookie %s 缺少 domain", ErrInvalidOption, c.Name)
+		}
+	}
+
+	if err := os.MkdirAll(s.sessionDir(name), 0o755); err != nil {
+		return SessionInfo{}, fmt.Errorf("创建会话目录失败: %v", err)
+	}
+
+	if len(cookies) > 0 {
+		inst, err := s.sessionInstance(name)
+		if err != nil {
+			return SessionInfo{}, err
+		}
+		browser, _ := inst.current()
+		if browser == nil {
+			return SessionInfo{}, ErrBrowserUnavailable
+		}
+		if err := browser.SetCookies(toCookieParams(cookies, "")); err != nil {
+			return SessionInfo{}, fmt.Errorf("导入 Cookie 失败: %v", err)
+		}
+	}
+	return s.sessionInfo(name)
+}
+
+// sessionInfo 读取单个会话信息
+func (s *Sniffer) sessionInfo(name string) (SessionInfo, error) {
+	dir := s.sessionDir(name)
+	st, err := os.Stat(dir)
+	if err != nil || !st.IsDir() {
+		return SessionInfo{}, fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+	}
+
+	info := SessionInfo{
+		Name:      name,
+		Dir:       dir,
+		UpdatedAt: st.ModTime().Format(time.RFC3339),
+	}
+
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	if sess, ok := s.sessions.sessions[name]; ok {
+		bs := sess.inst.stats()
+		info.Running = bs.Healthy
+		info.Pages = bs.Pages
+		info.LastUsedAt = sess.lastUsed.Format(time.RFC3339)
+	}
+	return info, nil
+}
+
+// Sessions 列出会话目录下的所有会话
+func (s *Sniffer) Sessions() ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.config.SessionDir)
+	if os.IsNotExist(err) {
+		return []SessionInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]SessionInfo, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() || checkSessionName(e.Name()) != nil {
+			continue
+		}
+		if info, err := s.sessionInfo(e.Name()); err == nil {
+			list = append(list, info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ExportSession 导出会话中的全部 Cookie
+func (s *Sniffer) ExportSession(name string) ([]Cookie, error) {
+	if _, err := s.sessionInfo(name); err != nil {
+		return nil, err
+	}
+	inst, err := s.sessionInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	browser, _ := inst.current()
+	if browser == nil {
+		return nil, ErrBrowserUnavailable
+	}
+
+	res, err := proto.StorageGetCookies{}.Call(browser)
+	if err != nil {
+		return nil, err
+	}
+	return fromNetworkCookies(res.Cookies), nil
+}
+
+// ArchiveSession 将会话的用户数据目录打包为 tar.gz 写入 w，包含 localStorage 和 IndexedDB。
+// 打包前会关闭会话浏览器以保证数据落盘，会话正在使用时返回 ErrSessionBusy。
+func (s *Sniffer) ArchiveSession(name string, w io.Writer) error {
+	if _, err := s.sessionInfo(name); err != nil {
+		return err
+	}
+	// 打包期间会话保持关闭，避免并发的请求重新启动浏览器写入目录
+	if err := s.beginArchive(name); err != nil {
+		return err
+	}
+	defer s.finishArchive(name)
+
+	dir := s.sessionDir(name)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// 跳过锁文件和套接字等非普通文件
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// DeleteSession 关闭会话浏览器并删除会话目录，会话正在使用时返回 ErrSessionBusy
+func (s *Sniffer) DeleteSession(name string) error {
+	if _, err := s.sessionInfo(name); err != nil {
+		return err
+	}
+	if err := s.stopSession(name, true); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.sessionDir(name))
+}
+
+// stopSession 关闭会话浏览器，forget 为 true 时同时移除会话记录
+func (s *Sniffer) stopSession(name string, forget bool) error {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	sess, ok := s.sessions.sessions[name]
+	if !ok {
+		return nil
+	}
+	if err := s.stopLocked(sess); err != nil {
+		return err
+	}
+	if forget {
+		delete(s.sessions.sessions, name)
+	}
+	return nil
+}
+
+// beginArchive 关闭会话浏览器并标记为正在导出，之后需调用 finishArchive
+func (s *Sniffer) beginArchive(name string) error {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	sess, ok := s.sessions.sessions[name]
+	if !ok {
+		sess = s.newSession(name)
+	}
+	if err := s.stopLocked(sess); err != nil {
+		return err
+	}
+	sess.archiving = true
+	return nil
+}
+
+// finishArchive 解除导出标记
+func (s *Sniffer) finishArchive(name string) {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	if sess, ok := s.sessions.sessions[name]; ok {
+		sess.archiving = false
+	}
+}
+
+// stopLocked 关闭会话浏览器，会话正在使用、启动或导出时返回 ErrSessionBusy。调用方需持有锁
+func (s *Sniffer) stopLocked(sess *session) error {
+	if sess.inst.load() > 0 || sess.starting != nil || sess.archiving {
+		return fmt.Errorf("%w: %s", ErrSessionBusy, sess.name)
+	}
+	if err := sess.inst.close(); err != nil {
+		s.log("关闭会话浏览器失败:", err)
+	}
+	return nil
+}
+
+// reapSessions 关闭空闲超时的会话浏览器，会话数据保留在磁盘上
+func (s *Sniffer) reapSessions() {
+	idle := time.Duration(s.config.SessionIdle) * time.Millisecond
+
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	for name, sess := range s.sessions.sessions {
+		if sess.inst.load() == 0 && time.Since(sess.lastUsed) > idle {
+			if err := sess.inst.close(); err != nil {
+				s.log("关闭会话浏览器失败:", err)
+			}
+			s.log("会话浏览器空闲关闭:", name)
+		}
+	}
+}
+
+// checkSessions 探测所有运行中的会话浏览器
+func (s *Sniffer) checkSessions() {
+	s.sessions.mu.Lock()
+	insts := make([]*browserInstance, 0, len(s.sessions.sessions))
+	for _, sess := range s.sessions.sessions {
+		insts = append(insts, sess.inst)
+	}
+	s.sessions.mu.Unlock()
+
+	for _, b := range insts {
+		s.checkSession(b)
+	}
+}
+
+// checkSession 探测会话浏览器，无响应时标记崩溃并结束进程，下次使用会话时重新启动
+func (s *Sniffer) checkSession(b *browserInstance) {
+	browser, generation := b.current()
+	if browser == nil {
+		return
+	}
+	timeout := time.Duration(s.config.HeadTimeout) * time.Millisecond
+	err := b.probe(timeout)
+	if err == nil {
+		return
+	}
+	if b.crashed(generation, err) {
+		s.log("会话浏览器不可用，已结束进程:", b.userDataDir, err)
+	}
+}
+
+// closeSessions 关闭所有会话浏览器
+func (s *Sniffer) closeSessions() {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+	for _, sess := range s.sessions.sessions {
+		sess.inst.close()
+	}
+}
@@ -0,0 +1,1140 @@
+// Package sniffer 基于 go-rod 的视频资源嗅探器，可嵌入其他 Go 服务直接调用
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// SnifferConfig 嗅探器配置
+type SnifferConfig struct {
+	Debug          bool        `json:"debug"`
+	Headless       bool        `json:"headless"`
+	UseChrome      bool        `json:"use_chrome"`
+	DeviceType     string      `json:"device_type"`
+	UserAgent      string      `json:"user_agent"`
+	Timeout        int         `json:"timeout"`
+	SnifferTimeout int         `json:"sniffer_timeout"`
+	HeadTimeout    int         `json:"head_timeout"`
+	ConcurrencyNum int         `json:"concurrency_num"`
+	BrowserNum     int         `json:"browser_num"`     // 浏览器进程数
+	HealthInterval int         `json:"health_interval"` // 浏览器健康探测间隔（毫秒）
+	QueueTimeout   int         `json:"queue_timeout"`   // 等待空闲页面的最长时间（毫秒）
+	PageMaxUses    int         `json:"page_max_uses"`   // 单个页面最多复用次数，超过后关闭重建
+	CustomRegex    string      `json:"custom_regex"`
+	Proxy          string      `json:"proxy"`         // 默认上游代理，请求未指定代理且未配置代理池时使用
+	ProxyPool      *ProxyPool  `json:"-"`             // 轮换代理池，请求未指定代理时从中选择
+	SessionDir     string      `json:"session_dir"`   // 命名会话的用户数据目录
+	SessionIdle    int         `json:"session_idle"`  // 会话浏览器空闲多久后关闭（毫秒）
+	Incognito      bool        `json:"incognito"`     // 所有请求都在独立的无痕上下文中执行，用完即销毁
+	BodyMaxSize    int         `json:"body_max_size"` // 扫描响应体的大小上限（字节）
+	SettleTime     int         `json:"settle_time"`   // mode 0 发现第一个地址后继续收集候选的时间（毫秒）
+	Filters        *FilterList `json:"-"`             // 广告过滤列表，见 LoadFilterLists
+}
+
+// Sniffer 嗅探器结构体
+type Sniffer struct {
+	config         *SnifferConfig
+	browsers       []*browserInstance
+	closing        chan struct{}
+	closeOnce      sync.Once
+	pool           *pagePool
+	sessions       *sessionManager
+	urlRegex       *regexp.Regexp
+	urlNoHead      *regexp.Regexp
+	excludeRegex   *regexp.Regexp
+	blockResources []string
+	filterBlocked  atomic.Int64 // 被过滤规则拦截的请求数
+	filterDropped  atomic.Int64 // 被过滤规则丢弃的候选地址数
+}
+
+// FilterStats 广告过滤统计
+type FilterStats struct {
+	Rules   int   `json:"rules"`   // 全局过滤列表规则数
+	Blocked int64 `json:"blocked"` // 累计拦截请求数
+	Dropped int64 `json:"dropped"` // 累计丢弃候选地址数
+}
+
+// SnifferOptions 嗅探选项
+type SnifferOptions struct {
+	Mode           int               `json:"mode"`
+	CustomRegex    string            `json:"custom_regex"`
+	SnifferExclude string            `json:"sniffer_exclude"`
+	Timeout        int               `json:"timeout"`
+	CSS            string            `json:"css"`
+	IsPc           *bool             `json:"is_pc"`        // 为 nil 时使用全局 DeviceType
+	Device         string            `json:"device"`       // 设备名称，见 Devices()，优先于 IsPc
+	Proxy          string            `json:"proxy"`        // 上游代理，见 ParseProxy，ProxyDirect 表示不使用代理
+	Cookies        []Cookie          `json:"cookies"`      // 导航前注入的 Cookie，见 ParseCookies
+	Session        string            `json:"session"`      // 命名持久会话，Cookie、localStorage、IndexedDB 跨请求保留
+	Incognito      bool              `json:"incognito"`    // 在独立的无痕上下文中执行，不与其他请求共享 Cookie 和存储
+	ScanBody       bool              `json:"scan_body"`    // 扫描 XHR、fetch 和文档响应体中的媒体地址
+	ValidateHLS    bool              `json:"validate_hls"` // 携带捕获的请求头下载并解析嗅探到的 m3u8
+	ParseDASH      bool              `json:"parse_dash"`   // 携带捕获的请求头下载并解析嗅探到的 DASH 清单
+	HeadProbe      bool              `json:"head_probe"`   // 对无扩展名的请求额外发起 HEAD 探测
+	CaptureMSE     bool              `json:"capture_mse"`  // 记录 MediaSource 各 SourceBuffer 的数据来源和 blob: 地址
+	NoFilter       bool              `json:"no_filter"`    // 不使用广告过滤规则
+	FilterRules    []string          `json:"filter_rules"` // 附加的 AdBlock 格式过滤规则，仅对本次请求生效
+	Headers        map[string]string `json:"headers"`
+	Script         string            `json:"script"`
+	InitScript     string            `json:"init_script"`
+	Actions        []Action          `json:"actions"` // 页面加载后、执行 Script 前按顺序执行的交互步骤
+	Frame          string            `json:"frame"`   // CSS 和 Script 作用的 iframe 或弹出窗口，按地址正则匹配
+
+	// OnCandidate 每收集到一个新的候选地址时调用，用于在嗅探结束前获取部分结果。
+	// 可能被多个协程同时调用，地址尚未打分，Frame 也未填写。
+	OnCandidate func(candidate URLWithHeaders) `json:"-"`
+	// OnEvent 嗅探进行到导航、CSS 等待、交互步骤和脚本执行等阶段时调用，见 Event
+	OnEvent func(event Event) `json:"-"`
+}
+
+// SnifferResult 嗅探结果
+type SnifferResult struct {
+	URL        string            `json:"url,omitempty"`
+	URLs       []URLWithHeaders  `json:"urls,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	From       string            `json:"from"`
+	Cost       string            `json:"cost"`
+	Script     string            `json:"script,omitempty"`
+	InitScript string            `json:"init_script,omitempty"`
+	Proxy      string            `json:"proxy,omitempty"`   // 实际使用的代理，已隐藏密码
+	Cookies    []Cookie          `json:"cookies,omitempty"` // 页面结束时的 Cookie
+	Source     string            `json:"source,omitempty"`  // mode 0 地址从响应体中发现时为所在响应的 URL
+	HLS        *HLSInfo          `json:"hls,omitempty"`     // mode 0 地址的 m3u8 校验结果
+	DASH       *DASHInfo         `json:"dash,omitempty"`    // mode 0 地址的 DASH 清单解析结果
+	Score      float64           `json:"score,omitempty"`   // mode 0 地址的排序得分
+	Reasons    []string          `json:"reasons,omitempty"` // mode 0 地址的得分依据
+	Blocked    int               `json:"blocked,omitempty"` // 被广告过滤规则拦截的请求数
+	Actions    []ActionResult    `json:"actions,omitempty"` // 交互步骤执行结果
+	Frame      string            `json:"frame,omitempty"`   // mode 0 地址所在的 iframe 或弹出窗口地址
+	MSE        *MSEInfo          `json:"mse,omitempty"`     // MediaSource 捕获结果，见 SnifferOptions.CaptureMSE
+}
+
+// URLWithHeaders URL和请求头
+type URLWithHeaders struct {
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Source      string            `json:"source,omitempty"`       // 从响应体中发现时为所在响应的 URL
+	HLS         *HLSInfo          `json:"hls,omitempty"`          // m3u8 校验结果，见 SnifferOptions.ValidateHLS
+	DASH        *DASHInfo         `json:"dash,omitempty"`         // DASH 清单解析结果，见 SnifferOptions.ParseDASH
+	ContentType string            `json:"content_type,omitempty"` // 按响应类型或 HEAD 探测发现时的响应类型
+	Frame       string            `json:"frame,omitempty"`        // 来自 iframe 或弹出窗口时为其文档地址
+	Score       float64           `json:"score"`                  // 排序得分，见 Reasons
+	Reasons     []string          `json:"reasons,omitempty"`      // 得分依据
+
+	custom bool // 由自定义正则匹配
+}
+
+// PageCodeResult 页面源码结果
+type PageCodeResult struct {
+	Code       string         `json:"code"`
+	From       string         `json:"from"`
+	Cost       string         `json:"cost"`
+	Script     string         `json:"script,omitempty"`
+	InitScript string         `json:"init_script,omitempty"`
+	Proxy      string         `json:"proxy,omitempty"`
+	Cookies    []Cookie       `json:"cookies,omitempty"`
+	Actions    []ActionResult `json:"actions,omitempty"`
+}
+
+// NewSniffer 创建新的嗅探器实例
+func NewSniffer(config *SnifferConfig) *Sniffer {
+	if config == nil {
+		config = &SnifferConfig{
+			Debug:          true,
+			Headless:       true,
+			UseChrome:      true,
+			DeviceType:     "mobile",
+			Timeout:        30000,
+			SnifferTimeout: 10000,
+			HeadTimeout:    5000,
+			ConcurrencyNum: 3,
+			BrowserNum:     1,
+			HealthInterval: 5000,
+			QueueTimeout:   30000,
+			PageMaxUses:    50,
+			SessionDir:     "sessions",
+			SessionIdle:    300000,
+			BodyMaxSize:    2 << 20,
+			SettleTime:     1500,
+		}
+	}
+
+	// 未设置的超时和并发数使用默认值，避免超时为 0 导致嗅探立即结束
+	if config.Timeout <= 0 {
+		config.Timeout = 30000
+	}
+	if config.SnifferTimeout <= 0 {
+		config.SnifferTimeout = 10000
+	}
+	if config.HeadTimeout <= 0 {
+		config.HeadTimeout = 5000
+	}
+	if config.ConcurrencyNum <= 0 {
+		config.ConcurrencyNum = 3
+	}
+	if config.BrowserNum <= 0 {
+		config.BrowserNum = 1
+	}
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = 5000
+	}
+	if config.SessionDir == "" {
+		config.SessionDir = "sessions"
+	}
+	if config.SessionIdle <= 0 {
+		config.SessionIdle = 300000
+	}
+	if config.SettleTime <= 0 {
+		config.SettleTime = 1500
+	}
+	if config.BodyMaxSize <= 0 {
+		config.BodyMaxSize = 2 << 20
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = 30000
+	}
+	if config.PageMaxUses <= 0 {
+		config.PageMaxUses = 50
+	}
+
+	// 默认正则表达式 - 兼容 Go RE2 引擎（不支持负向前瞻）
+	// 匹配包含媒体文件扩展名的 URL
+	urlRegex := regexp.MustCompile(`(?i)https?://[^\s"'<>]{12,}?\.(m3u8|mpd|mp4|flv|avi|mkv|rm|wmv|mpg|m4a|mp3)(\?[^\s"'<>]*)?|https?://[^\s"'<>]*?(video|obj)/tos[^\s"'<>]*`)
+	urlNoHead := regexp.MustCompile(`https?://[^\s"'<>]{12,}?(ac=dm&url=)`)
+
+	// 阻止的资源类型
+	blockResources := []string{
+		"image", "stylesheet", "font", "texttrack", "object", "beacon",
+		"csp_report", "imageset", "media",
+	}
+
+	return &Sniffer{
+		config:         config,
+		sessions:       &sessionManager{sessions: make(map[string]*session)},
+		urlRegex:       urlRegex,
+		urlNoHead:      urlNoHead,
+		blockResources: blockResources,
+	}
+}
+
+// log 日志输出
+func (s *Sniffer) log(args ...interface{}) {
+	if s.config.Debug {
+		log.Println(args...)
+	}
+}
+
+// newLauncher 创建带反检测启动参数的浏览器启动器
+func (s *Sniffer) newLauncher() *launcher.Launcher {
+	var l *launcher.Launcher
+
+	if s.config.UseChrome {
+		// 查找系统中的 Chrome
+		l = launcher.New().Headless(s.config.Headless)
+	} else {
+		// 使用内置浏览器
+		l = launcher.New().Headless(s.config.Headless)
+	}
+
+	// 设置启动参数
+	l = l.Set("disable-blink-features", "AutomationControlled").
+		Set("disable-features", "VizDisplayCompositor,TranslateUI").
+		Set("disable-ipc-flooding-protection").
+		Set("disable-renderer-backgrounding").
+		Set("disable-backgrounding-occluded-windows").
+		Set("disable-background-timer-throttling").
+		Set("disable-background-networking").
+		Set("disable-breakpad").
+		Set("disable-client-side-phishing-detection").
+		Set("disable-component-extensions-with-background-pages").
+		Set("disable-default-apps").
+		Set("disable-dev-shm-usage").
+		Set("disable-extensions").
+		Set("disable-features", "TranslateUI").
+		Set("disable-hang-monitor").
+		Set("disable-popup-blocking").
+		Set("disable-prompt-on-repost").
+		Set("disable-sync").
+		Set("disable-web-security").
+		Set("metrics-recording-only").
+		Set("no-first-run").
+		Set("no-default-browser-check").
+		Set("password-store", "basic").
+		Set("use-mock-keychain").
+		Set("no-sandbox").
+		Set("disable-setuid-sandbox")
+
+	return l
+}
+
+// InitBrowser 启动 BrowserNum 个浏览器进程并开始健康探测
+func (s *Sniffer) InitBrowser() error {
+	browsers := make([]*browserInstance, 0, s.config.BrowserNum)
+	for i := 0; i < s.config.BrowserNum; i++ {
+		b := &browserInstance{id: i}
+		if err := b.start(s); err != nil {
+			for _, started := range browsers {
+				started.close()
+			}
+			return err
+		}
+		browsers = append(browsers, b)
+	}
+
+	s.browsers = browsers
+	s.closing = make(chan struct{})
+	s.pool = newPagePool(
+		s.config.ConcurrencyNum,
+		time.Duration(s.config.QueueTimeout)*time.Millisecond,
+		s.config.PageMaxUses,
+		s.newPooledPage,
+		s.log,
+	)
+	go s.healthLoop()
+
+	s.log("浏览器初始化成功，进程数:", len(browsers))
+	return nil
+}
+
+// newPooledPage 在负载最低的健康浏览器实例上创建页面，opts 不为空时创建在独立上下文中
+func (s *Sniffer) newPooledPage(opts *contextOptions) (*pooledPage, error) {
+	// 会话页面创建在会话自己的浏览器进程中
+	if opts != nil && opts.session != nil {
+		browser, generation := opts.session.current()
+		if browser == nil {
+			return nil, ErrBrowserUnavailable
+		}
+		page, err := browser.Page(proto.TargetCreateTarget{})
+		if err != nil {
+			// 会话浏览器崩溃后由下一个请求重新启动
+			go s.checkSession(opts.session)
+			return nil, err
+		}
+		opts.session.pageOpened(generation)
+		return &pooledPage{page: page, owner: opts.session, generation: generation, dedicated: true}, nil
+	}
+
+	b, err := s.pickBrowser()
+	if err != nil {
+		return nil, err
+	}
+
+	browser, generation := b.current()
+	if browser == nil {
+		return nil, ErrBrowserUnavailable
+	}
+	var page *rod.Page
+	var contextID proto.BrowserBrowserContextID
+	if opts != nil {
+		page, contextID, err = newIsolatedPage(browser, opts)
+	} else {
+		page, err = browser.Page(proto.TargetCreateTarget{})
+	}
+	if err != nil {
+		// 创建页面失败时立即探测，避免继续路由到已崩溃的实例
+		go s.checkBrowser(b)
+		return nil, err
+	}
+
+	b.pageOpened(generation)
+	return &pooledPage{page: page, owner: b, generation: generation, contextID: contextID}, nil
+}
+
+// Ready 是否存在可用的浏览器实例
+func (s *Sniffer) Ready() bool {
+	for _, b := range s.browsers {
+		if b.load() >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterStats 返回广告过滤统计
+func (s *Sniffer) FilterStats() FilterStats {
+	return FilterStats{
+		Rules:   s.config.Filters.Rules(),
+		Blocked: s.filterBlocked.Load(),
+		Dropped: s.filterDropped.Load(),
+	}
+}
+
+// PoolStats 返回页面池状态
+func (s *Sniffer) PoolStats() PoolStats {
+	if s.pool == nil {
+		return PoolStats{Capacity: s.config.ConcurrencyNum}
+	}
+	return s.pool.stats()
+}
+
+// GetPage 从页面池获取页面并按请求选项设置设备模拟和请求头，
+// 池满时排队等待直到 ctx 取消或排队超时。使用完毕后必须调用 ClosePage 归还。
+func (s *Sniffer) GetPage(ctx context.Context, options *SnifferOptions) (*rod.Page, error) {
+	if s.pool == nil {
+		return nil, ErrBrowserNotReady
+	}
+
+	device, ok := s.resolveDevice(options)
+	if !ok {
+		return nil, fmt.Errorf("%w: 未知设备 %s", ErrInvalidOption, options.Device)
+	}
+
+	// 使用无痕模式、代理或注入 Cookie 时在独立上下文中创建页面，避免影响其他请求；
+	// 使用会话时页面创建在会话浏览器中，Cookie 等数据持久保存在会话里
+	proxy, err := s.resolveProxy(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Session != "" && options.Incognito {
+		return nil, fmt.Errorf("%w: 会话和无痕模式不能同时使用", ErrInvalidOption)
+	}
+	var ctxOpts *contextOptions
+	if options.Session != "" {
+		inst, err := s.sessionInstance(options.Session)
+		if err != nil {
+			return nil, err
+		}
+		ctxOpts = &contextOptions{session: inst}
+	} else if options.Incognito || s.config.Incognito || proxy != nil || len(options.Cookies) > 0 {
+		ctxOpts = &contextOptions{proxy: proxy}
+	}
+
+	page, err := s.pool.acquire(ctx, ctxOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置设备模拟
+	if err := emulateDevice(page, device); err != nil {
+		s.log("设备模拟失败:", err)
+	}
+
+	// 设置额外的请求头
+	if len(options.Headers) > 0 {
+		headerList := make([]string, 0, len(options.Headers)*2)
+		for k, v := range options.Headers {
+			headerList = append(headerList, k, v)
+		}
+		_, err = page.SetExtraHeaders(headerList)
+		if err != nil {
+			s.log("设置额外请求头失败:", err)
+		}
+	}
+
+	// 注释掉资源阻止逻辑，避免与主要的嗅探拦截器冲突
+	// 资源阻止将在主要的嗅探拦截器中处理
+
+	return page, nil
+}
+
+// shouldBlockResource 检查是否应该阻止资源
+func (s *Sniffer) shouldBlockResource(resourceType string) bool {
+	for _, blockType := range s.blockResources {
+		if resourceType == blockType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosePage 归还页面，页面重置后留在池中供后续任务复用
+func (s *Sniffer) ClosePage(page *rod.Page) {
+	s.releasePage(page, false)
+}
+
+// releasePage 归还页面，discard 为 true 时直接关闭页面，不放回池中复用
+func (s *Sniffer) releasePage(page *rod.Page, discard bool) {
+	if page != nil {
+		s.pool.release(page, discard)
+	}
+}
+
+// closePageAfter 任务结束时归还页面，调用方已取消时页面可能停在任意状态，直接关闭
+func (s *Sniffer) closePageAfter(ctx context.Context, page *rod.Page) {
+	s.releasePage(page, ctx.Err() != nil)
+}
+
+// Close 停止健康探测并关闭所有浏览器进程，可以重复调用
+func (s *Sniffer) Close() error {
+	if s.closing == nil {
+		return nil
+	}
+
+	var firstErr error
+	s.closeOnce.Do(func() {
+		close(s.closing)
+
+		s.closeSessions()
+
+		for _, b := range s.browsers {
+			if err := b.close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("关闭浏览器失败: %v", err)
+			}
+		}
+		s.log("浏览器已关闭")
+	})
+	return firstErr
+}
+
+// closed 嗅探器是否已关闭
+func (s *Sniffer) closed() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValidURL 检查 URL 是否有效
+func (s *Sniffer) IsValidURL(urlStr string) bool {
+	_, err := url.Parse(urlStr)
+	return err == nil && (strings.HasPrefix(urlStr, "http://") || strings.HasPrefix(urlStr, "https://"))
+}
+
+// IsRealURLCheck 检查是否为真实媒体 URL
+func (s *Sniffer) IsRealURLCheck(urlStr string) bool {
+	// 排除一些明显不是媒体文件的 URL
+	excludePatterns := []string{
+		"google", "facebook", "twitter", "analytics", "doubleclick",
+		".css", ".js", ".html", ".htm", ".png", ".jpg", ".jpeg", ".gif",
+	}
+
+	lowerURL := strings.ToLower(urlStr)
+	for _, pattern := range excludePatterns {
+		if strings.Contains(lowerURL, pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPlayableURL 排除把媒体地址作为参数传递的解析接口和页面、样式地址
+func isPlayableURL(urlStr string) bool {
+	return !strings.Contains(urlStr, "url=http") && !strings.Contains(urlStr, "v=http") &&
+		!strings.Contains(urlStr, ".css") && !strings.Contains(urlStr, ".html")
+}
+
+// isManifestResponse 根据 HEAD 响应判断是否为 m3u8 或 DASH 清单
+func isManifestResponse(contentType, contentDisposition string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "application/dash+xml") {
+		return true
+	}
+	return contentType == "application/octet-stream" &&
+		(strings.Contains(contentDisposition, ".m3u8") || strings.Contains(contentDisposition, ".mpd"))
+}
+
+// CanHeadCheck 检查是否可以进行 HEAD 请求
+func (s *Sniffer) CanHeadCheck(urlStr string) bool {
+	// 简单的检查逻辑
+	return !s.urlNoHead.MatchString(urlStr)
+}
+
+// pageError 包装获取页面失败的错误，排队超时、参数错误和取消等保留原始错误类型
+func pageError(op, url string, err error) *Error {
+	if errors.Is(err, ErrQueueTimeout) || errors.Is(err, ErrBrowserNotReady) || errors.Is(err, ErrBrowserUnavailable) ||
+		errors.Is(err, ErrInvalidOption) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return newError(op, url, err, nil)
+	}
+	return newError(op, url, ErrPage, err)
+}
+
+// costString 格式化耗时
+func costString(startTime time.Time) string {
+	return fmt.Sprintf("%d ms", time.Since(startTime).Milliseconds())
+}
+
+// SnifferMediaURL 嗅探媒体 URL
+//
+// ctx 取消时嗅探提前结束。未嗅探到媒体地址时仍返回包含耗时等信息的结果，
+// 同时返回 ErrNotFound 类型的错误。
+func (s *Sniffer) SnifferMediaURL(ctx context.Context, playURL string, options *SnifferOptions) (*SnifferResult, error) {
+	startTime := time.Now()
+
+	if options == nil {
+		options = &SnifferOptions{
+			Mode:    0,
+			Timeout: s.config.SnifferTimeout,
+		}
+	}
+
+	// 验证 URL
+	if !s.IsValidURL(playURL) {
+		return nil, newError("sniffer", playURL, ErrInvalidURL, nil)
+	}
+	frameRegex, err := compileFrame(options.Frame)
+	if err != nil {
+		return nil, newError("sniffer", playURL, err, nil)
+	}
+
+	// 广告过滤：全局过滤列表和请求附加规则，NoFilter 时不过滤
+	var filters []*FilterList
+	if !options.NoFilter {
+		filters = append(filters, s.config.Filters)
+		if len(options.FilterRules) > 0 {
+			filters = append(filters, NewFilterList(options.FilterRules))
+		}
+	}
+	var blocked atomic.Int64
+
+	// 候选地址按媒体资源匹配，$script、$image 等限定了其他类型的规则不会丢弃媒体地址
+	candidates := newCollector(func(candidateURL string) bool {
+		if rule := matchFilters(newFilterRequest(candidateURL, playURL, string(proto.NetworkResourceTypeMedia)), filters...); rule != "" {
+			s.filterDropped.Add(1)
+			s.log("候选地址命中过滤规则:", candidateURL, "规则:", rule)
+			return true
+		}
+		return false
+	})
+	candidates.onAdd = options.OnCandidate
+	emit := eventEmitter(options, startTime)
+	// 记录请求头，按响应类型识别出媒体地址时使用
+	var requestHeaders sync.Map
+
+	options, proxy, fromPool, err := s.assignProxy(options, playURL)
+	if err != nil {
+		return nil, newError("sniffer", playURL, err, nil)
+	}
+
+	page, err := s.GetPage(ctx, options)
+	if err != nil {
+		return nil, pageError("sniffer", playURL, err)
+	}
+	defer s.closePageAfter(ctx, page)
+
+	client := s.headClient(proxy)
+	defer client.CloseIdleConnections()
+
+	// 设置超时
+	timeout := time.Duration(options.Timeout) * time.Millisecond
+	if options.Mode == 1 {
+		if options.Timeout <= 0 || options.Timeout > s.config.Timeout {
+			timeout = time.Duration(s.config.Timeout) * time.Millisecond
+		}
+	} else {
+		if options.Timeout <= 0 || options.Timeout > s.config.SnifferTimeout {
+			timeout = time.Duration(s.config.SnifferTimeout) * time.Millisecond
+		}
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// mode 0 发现第一个地址后再等待一小段时间收集其他候选，排序后返回最佳地址
+	var settleOnce sync.Once
+	found := func() {
+		if options.Mode == 0 {
+			settleOnce.Do(func() {
+				time.AfterFunc(time.Duration(s.config.SettleTime)*time.Millisecond, cancel)
+			})
+		}
+	}
+
+	// 自定义正则和排除正则，格式错误时忽略
+	var customRegex, excludeRegex *regexp.Regexp
+	if options.CustomRegex != "" {
+		customRegex, _ = regexp.Compile("(?mi)" + options.CustomRegex)
+	}
+	if options.SnifferExclude != "" {
+		excludeRegex, _ = regexp.Compile("(?mi)" + options.SnifferExclude)
+	}
+
+	// 请求拦截器
+	onRequest := func(hijack *rod.Hijack) {
+		reqURL := hijack.Request.URL().String()
+		method := hijack.Request.Method()
+		headers := hijack.Request.Headers()
+		resourceType := hijack.Request.Type()
+
+		s.log("on_request:", reqURL, "method:", method, "type:", resourceType)
+
+		// 检查是否需要阻止的资源类型
+		if s.shouldBlockResource(string(resourceType)) {
+			s.log("blocking resource type:", resourceType, "for URL:", reqURL)
+			hijack.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+			return
+		}
+
+		if strings.ToLower(method) == "get" {
+			requestHeaders.Store(reqURL, candidateHeaders(headers))
+		}
+
+		// 拦截命中广告过滤规则的请求，目标页面本身除外
+		if reqURL != playURL {
+			if rule := matchFilters(newFilterRequest(reqURL, playURL, string(resourceType)), filters...); rule != "" {
+				blocked.Add(1)
+				s.filterBlocked.Add(1)
+				s.log("blocking by filter:", reqURL, "rule:", rule)
+				hijack.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+				return
+			}
+		}
+
+		// 添加调试：检查是否匹配默认正则
+		if s.urlRegex.MatchString(reqURL) {
+			s.log("URL matches urlRegex:", reqURL)
+			if s.IsRealURLCheck(reqURL) {
+				s.log("URL passes IsRealURLCheck:", reqURL)
+			} else {
+				s.log("URL fails IsRealURLCheck:", reqURL)
+			}
+		}
+
+		// 检查排除正则
+		if excludeRegex != nil && excludeRegex.MatchString(reqURL) {
+			hijack.ContinueRequest(&proto.FetchContinueRequest{})
+			return
+		}
+
+		// 检查自定义正则
+		if customRegex != nil && customRegex.MatchString(reqURL) {
+			if candidates.add(URLWithHeaders{URL: reqURL, Headers: candidateHeaders(headers), custom: true}) {
+				s.log("通过custom_regex嗅探到真实地址:", reqURL)
+				found()
+			}
+			hijack.ContinueRequest(&proto.FetchContinueRequest{})
+			return
+		}
+
+		// 检查默认正则
+		if s.urlRegex.MatchString(reqURL) && s.IsRealURLCheck(reqURL) {
+			if isPlayableURL(reqURL) {
+
+				if candidates.add(URLWithHeaders{URL: reqURL, Headers: candidateHeaders(headers)}) {
+					s.log("通过默认正则嗅探到真实地址:", reqURL)
+					found()
+				}
+			}
+		} else if options.HeadProbe && strings.ToLower(method) == "get" && strings.HasPrefix(reqURL, "http") && reqURL != playURL {
+			// HEAD 请求检查逻辑，浏览器响应监听无法覆盖时的补充手段
+			parsedURL, err := url.Parse(reqURL)
+			if err == nil {
+				path := parsedURL.Path
+				filename := ""
+				if idx := strings.LastIndex(path, "/"); idx >= 0 {
+					filename = path[idx+1:]
+				}
+
+				shouldCheck := (filename != "" && !strings.Contains(filename, ".") && !s.urlNoHead.MatchString(reqURL)) ||
+					(strings.Contains(filename, ".") && len(filename) > 1)
+
+				if shouldCheck && s.CanHeadCheck(reqURL) && candidates.tryProbe(reqURL) {
+					go func(checkURL string) {
+						req, err := http.NewRequestWithContext(ctx, "HEAD", checkURL, nil)
+						if err != nil {
+							s.log("创建HEAD请求失败:", err)
+							return
+						}
+
+						resp, err := client.Do(req)
+						if err != nil {
+							s.log("HEAD请求失败:", err)
+							return
+						}
+						defer resp.Body.Close()
+
+						contentType := resp.Header.Get("content-type")
+						contentDisposition := resp.Header.Get("content-disposition")
+
+						if isManifestResponse(contentType, contentDisposition) {
+							candidate := URLWithHeaders{URL: checkURL, Headers: candidateHeaders(headers), ContentType: contentType}
+							if candidates.add(candidate) {
+								s.log("通过head请求嗅探到真实地址:", checkURL)
+								found()
+							}
+						}
+					}(reqURL)
+				}
+			}
+		}
+
+		hijack.ContinueRequest(&proto.FetchContinueRequest{})
+	}
+
+	// 根据浏览器收到的响应类型识别媒体地址
+	onMediaResponse := func(r mediaResponse) {
+		if !s.IsRealURLCheck(r.URL) || (excludeRegex != nil && excludeRegex.MatchString(r.URL)) {
+			return
+		}
+		candidate := URLWithHeaders{URL: r.URL, ContentType: r.ContentType}
+		if h, ok := requestHeaders.Load(r.URL); ok {
+			candidate.Headers = h.(map[string]string)
+		} else {
+			candidate.Headers = map[string]string{"referer": playURL}
+		}
+		if candidates.add(candidate) {
+			s.log("通过响应类型嗅探到真实地址:", r.URL, "类型:", r.ContentType)
+			found()
+		}
+	}
+
+	// 扫描接口和文档响应体中的媒体地址
+	onBodyURL := func(bodyURL, source string) {
+		if !s.isBodyMediaURL(bodyURL, customRegex, excludeRegex) {
+			return
+		}
+		candidate := URLWithHeaders{
+			URL:     bodyURL,
+			Headers: map[string]string{"referer": playURL},
+			Source:  source,
+		}
+		if candidates.add(candidate) {
+			s.log("通过响应体嗅探到真实地址:", bodyURL, "来源:", source)
+			found()
+		}
+	}
+
+	// MediaSource 捕获
+	var mse *mseCapture
+	if options.CaptureMSE {
+		mse = newMSECapture()
+	}
+
+	// 在主页面、跨进程 iframe 和弹出窗口上挂载相同的拦截器和监听器
+	watchTarget := func(p *rod.Page) func() {
+		router := p.HijackRequests()
+		router.MustAdd("*", onRequest)
+		go router.Run()
+
+		// 代理认证需在拦截器启用 Fetch 之后设置
+		if err := handleProxyAuth(ctx, p, proxy, false); err != nil {
+			s.log("设置代理认证失败:", err)
+		}
+		watchMediaResponses(ctx, p, onMediaResponse)
+		if options.ScanBody {
+			s.scanResponseBodies(ctx, p, s.config.BodyMaxSize, onBodyURL)
+		}
+		removeMSE := func() {}
+		if mse != nil {
+			removeMSE = mse.watch(ctx, p)
+		}
+		return func() {
+			_ = router.Stop()
+			removeMSE()
+		}
+	}
+	defer watchTarget(page)()
+	frames := watchFrames(ctx, page, watchTarget)
+	defer frames.close()
+
+	// 执行初始化脚本
+	if options.InitScript != "" {
+		s.log("开始执行页面初始化js:", options.InitScript)
+		remove, err := page.EvalOnNewDocument(options.InitScript)
+		if err != nil {
+			s.log("执行页面初始化js发生错误:", err)
+		} else {
+			// 页面会被复用，任务结束后移除初始化脚本
+			defer remove()
+		}
+	}
+
+	// 注入 Cookie
+	if err := setCookies(page, options.Cookies, playURL); err != nil {
+		s.log("设置Cookie失败:", err)
+	}
+
+	// 导航到页面
+	navStart := time.Now()
+	var navLatency time.Duration
+	navErr := rod.Try(func() {
+		page.Context(ctx).MustNavigate(playURL)
+		navLatency = time.Since(navStart)
+		// 尝试等待页面加载，但不强制要求成功
+		rod.Try(func() {
+			page.Context(ctx).MustWaitLoad()
+		})
+	})
+	if navErr != nil {
+		s.log("页面导航失败:", navErr)
+		navLatency = time.Since(navStart)
+		// 继续执行，不要因为导航失败就停止
+	}
+	emit(Event{Type: EventNavigate, URL: playURL}, navErr)
+
+	// CSS 和脚本的作用页面
+	var target *rod.Page
+	if options.CSS != "" || options.Script != "" {
+		target = s.scriptTarget(ctx, page, frames, frameRegex)
+	}
+
+	// 等待 CSS 选择器
+	if options.CSS != "" && target != nil {
+		err = rod.Try(func() {
+			target.Context(ctx).MustElement(options.CSS)
+		})
+		if err != nil {
+			s.log("等待CSS选择器失败:", err)
+		}
+		emit(Event{Type: EventCSS}, err)
+	}
+
+	// 执行交互步骤
+	actionResults := s.runActions(ctx, page, options.Actions)
+	if len(actionResults) > 0 {
+		emit(Event{Type: EventActions, Actions: actionResults}, nil)
+	}
+
+	// 执行页面脚本
+	if options.Script != "" && target != nil {
+		s.log("开始执行网页js:", options.Script)
+		jsCode := fmt.Sprintf(`
+			var scriptTimer;
+			var scriptCounter = 0;
+			scriptTimer = setInterval(function(){
+				if(location.href !== 'about:blank'){
+					scriptCounter += 1;
+					console.log('---第' + scriptCounter + '次执行script[' + location.href + ']---');
+					%s
+					clearInterval(scriptTimer);
+					scriptCounter = 0;
+					console.log('---执行script成功---');
+				}
+			}, 200);
+		`, options.Script)
+
+		err = rod.Try(func() {
+			target.Context(ctx).MustEval(jsCode)
+		})
+		if err != nil {
+			s.log("执行页面脚本失败:", err)
+		}
+		emit(Event{Type: EventScript}, err)
+	}
+
+	// 等待结果：mode 0 找到第一个 URL 并等待收集窗口结束或超时，mode 1 等待指定时间收集所有 URL
+	<-ctx.Done()
+
+	// 停止收集，之后才完成的 HEAD 探测结果会被丢弃
+	realURLs := candidates.close()
+	frames.annotate(realURLs)
+
+	// 校验 m3u8 播放列表和 DASH 清单，解析出的时长参与排序
+	if options.ValidateHLS {
+		validateHLS(parent, client, realURLs)
+	}
+	if options.ParseDASH {
+		parseDASHCandidates(parent, client, realURLs)
+	}
+	rankCandidates(realURLs)
+
+	cost := time.Since(startTime)
+	costStr := fmt.Sprintf("%d ms", cost.Milliseconds())
+
+	s.log("共计耗时", cost.Milliseconds(), "毫秒")
+	s.log("realURLs:", realURLs)
+
+	// 调用方取消且没有任何结果时直接返回取消原因
+	if len(realURLs) == 0 && parent.Err() != nil {
+		return nil, newError("sniffer", playURL, parent.Err(), nil)
+	}
+
+	// 上报代理池：导航成功或已嗅探到地址都说明代理可用
+	if fromPool {
+		s.config.ProxyPool.Report(proxy, navErr == nil || len(realURLs) > 0, navLatency)
+	}
+
+	result := &SnifferResult{
+		From:       playURL,
+		Cost:       costStr,
+		Script:     options.Script,
+		InitScript: options.InitScript,
+		Proxy:      redacted(proxy),
+		Blocked:    int(blocked.Load()),
+		Actions:    actionResults,
+		MSE:        mse.result(),
+	}
+
+	// 捕获页面最终的 Cookie
+	if result.Cookies, err = captureCookies(page, playURL); err != nil {
+		s.log("获取Cookie失败:", err)
+	}
+
+	// 返回结果
+	switch {
+	case options.Mode == 0 && len(realURLs) > 0:
+		result.URL = realURLs[0].URL
+		result.Headers = realURLs[0].Headers
+		result.Source = realURLs[0].Source
+		result.HLS = realURLs[0].HLS
+		result.DASH = realURLs[0].DASH
+		result.Score = realURLs[0].Score
+		result.Reasons = realURLs[0].Reasons
+		result.Frame = realURLs[0].Frame
+	case options.Mode == 1 && len(realURLs) > 0:
+		result.URLs = realURLs
+	default:
+		result.Headers = make(map[string]string)
+		return result, newError("sniffer", playURL, ErrNotFound, nil)
+	}
+	return result, nil
+}
+
+// FetCodeByWebView 获取页面源码
+//
+// 失败时返回的结果仍包含耗时等信息，错误类型可通过 errors.Is 判断。
+func (s *Sniffer) FetCodeByWebView(ctx context.Context, pageURL string, options *SnifferOptions) (*PageCodeResult, error) {
+	startTime := time.Now()
+
+	if options == nil {
+		options = &SnifferOptions{
+			Timeout: s.config.Timeout,
+		}
+	}
+
+	// 验证 URL
+	if !s.IsValidURL(pageURL) {
+		return nil, newError("fetch", pageURL, ErrInvalidURL, nil)
+	}
+	frameRegex, err := compileFrame(options.Frame)
+	if err != nil {
+		return nil, newError("fetch", pageURL, err, nil)
+	}
+
+	options, proxy, fromPool, err := s.assignProxy(options, pageURL)
+	if err != nil {
+		return nil, newError("fetch", pageURL, err, nil)
+	}
+
+	page, err := s.GetPage(ctx, options)
+	if err != nil {
+		return nil, pageError("fetch", pageURL, err)
+	}
+	defer s.closePageAfter(ctx, page)
+
+	// 设置超时
+	timeout := time.Duration(options.Timeout) * time.Millisecond
+	if options.Timeout <= 0 {
+		timeout = time.Duration(s.config.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 代理认证，未挂载拦截器时由认证处理放行其他请求
+	if err := handleProxyAuth(ctx, page, proxy, true); err != nil {
+		s.log("设置代理认证失败:", err)
+	}
+
+	// 指定 frame 时自动附加跨进程 iframe 和弹出窗口，供 CSS 和脚本查找
+	var frames *frameWatcher
+	if frameRegex != nil {
+		frames = watchFrames(ctx, page, nil)
+		defer frames.close()
+	}
+
+	// 执行初始化脚本
+	if options.InitScript != "" {
+		s.log("开始执行页面初始化js:", options.InitScript)
+		remove, err := page.EvalOnNewDocument(options.InitScript)
+		if err != nil {
+			s.log("执行页面初始化js发生错误:", err)
+		} else {
+			// 页面会被复用，任务结束后移除初始化脚本
+			defer remove()
+		}
+	}
+
+	// 注入 Cookie
+	if err := setCookies(page, options.Cookies, pageURL); err != nil {
+		s.log("设置Cookie失败:", err)
+	}
+
+	// 导航到页面
+	navStart := time.Now()
+	err = rod.Try(func() {
+		page.Context(ctx).MustNavigate(pageURL).MustWaitLoad()
+	})
+	if fromPool {
+		s.config.ProxyPool.Report(proxy, err == nil, time.Since(navStart))
+	}
+	if err != nil {
+		s.log("页面导航失败:", err)
+		return &PageCodeResult{
+			From:  pageURL,
+			Cost:  costString(startTime),
+			Proxy: redacted(proxy),
+		}, newError("fetch", pageURL, ErrNavigation, err)
+	}
+
+	// CSS 和脚本的作用页面
+	var target *rod.Page
+	if options.CSS != "" || options.Script != "" {
+		target = s.scriptTarget(ctx, page, frames, frameRegex)
+	}
+
+	// 等待 CSS 选择器
+	if options.CSS != "" && target != nil {
+		err = rod.Try(func() {
+			target.Context(ctx).MustElement(options.CSS)
+		})
+		if err != nil {
+			s.log("等待CSS选择器失败:", err)
+		}
+	}
+
+	// 执行交互步骤
+	actionResults := s.runActions(ctx, page, options.Actions)
+
+	// 执行页面脚本
+	if options.Script != "" && target != nil {
+		s.log("开始执行网页js:", options.Script)
+		err = rod.Try(func() {
+			target.Context(ctx).MustEval(options.Script)
+		})
+		if err != nil {
+			s.log("执行页面脚本失败:", err)
+		}
+	}
+
+	// 获取页面源码
+	var htmlContent string
+	err = rod.Try(func() {
+		htmlContent = page.Context(ctx).MustHTML()
+	})
+	if err != nil {
+		s.log("获取页面源码失败:", err)
+		return &PageCodeResult{
+			From:    pageURL,
+			Cost:    costString(startTime),
+			Proxy:   redacted(proxy),
+			Actions: actionResults,
+		}, newError("fetch", pageURL, ErrContent, err)
+	}
+
+	cookies, err := captureCookies(page, pageURL)
+	if err != nil {
+		s.log("获取Cookie失败:", err)
+	}
+
+	cost := time.Since(startTime)
+	s.log("获取页面源码成功，耗时", cost.Milliseconds(), "毫秒")
+
+	return &PageCodeResult{
+		Code:       htmlContent,
+		From:       pageURL,
+		Cost:       fmt.Sprintf("%d ms", cost.Milliseconds()),
+		Script:     options.Script,
+		InitScript: options.InitScript,
+		Proxy:      redacted(proxy),
+		Cookies:    cookies,
+		Actions:    actionResults,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pup-sniffer/sniffer"
+)
+
+// SSE 事件名称，过程事件使用 sniffer.Event 的类型
+const (
+	streamCandidate = "candidate" // 发现新的候选地址
+	streamDone      = "done"      // 嗅探结束，数据与 /sniffer 的 data 相同
+	streamError     = "error"     // 初始化嗅探器失败
+)
+
+// streamEvent 待发送的 SSE 事件
+type streamEvent struct {
+	name string
+	data interface{}
+}
+
+// handleSnifferStream 以 SSE 推送嗅探过程，参数与 GET /sniffer 相同。
+// 客户端拿到需要的地址后断开连接即可提前结束嗅探并关闭页面。
+func (s *Server) handleSnifferStream(c *gin.Context) {
+	startTime := time.Now()
+	targetURL, options, ok := snifferQuery(c)
+	if !ok {
+		return
+	}
+	cache, ok := queryCacheMode(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	events := make(chan streamEvent, 64)
+	stop := make(chan struct{})
+	defer close(stop)
+	send := func(name string, data interface{}) {
+		select {
+		case events <- streamEvent{name: name, data: data}:
+		case <-stop:
+		}
+	}
+	options.OnCandidate = func(u sniffer.URLWithHeaders) { send(streamCandidate, u) }
+	options.OnEvent = func(e sniffer.Event) { send(e.Type, e) }
+
+	// 嗅探在单独的协程中执行，客户端断开后处理器先返回，不能再使用 c
+	present := queryPresent(c)
+	finished := make(chan streamEvent, 1)
+	go func() {
+		result, err := s.sniff(ctx, startTime, targetURL, options, present, cache)
+		if err != nil {
+			finished <- streamEvent{name: streamError, data: createErrorResponse(fmt.Sprintf("初始化嗅探器失败: %v", err), 500)}
+			return
+		}
+		finished <- streamEvent{name: streamDone, data: result}
+	}()
+
+	// 关闭代理缓冲，保证事件及时送达
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e := <-events:
+			c.SSEvent(e.name, e.data)
+			return true
+		case e := <-finished:
+			// 先发送嗅探结束前已排队的事件
+			for drained := false; !drained; {
+				select {
+				case pending := <-events:
+					c.SSEvent(pending.name, pending.data)
+				default:
+					drained = true
+				}
+			}
+			c.SSEvent(e.name, e.data)
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	})
+}